@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/vm"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Command is a REPL command, typed as a line starting with ':'. Run is given
+// whatever follows the command's name.
+type Command struct {
+	Name string
+	Args string
+	Help string
+	Run  func(l *Lox, argument string)
+}
+
+var Commands = []Command{
+	{":load", "file", "run a file in this session", (*Lox).Load},
+	{":env", "", "list the variables in scope and their values", (*Lox).Env},
+	{":reset", "", "start over with a fresh interpreter", (*Lox).Reset},
+	{":ast", "source", "print the syntax tree of an expression or statements", (*Lox).Ast},
+	{":tokens", "source", "print the tokens source scans to", (*Lox).Tokens},
+	{":time", "source", "run source and report how long it took", (*Lox).Time},
+}
+
+func IsCommand(source string) bool {
+	return strings.HasPrefix(strings.TrimSpace(source), ":")
+}
+
+func (l *Lox) RunCommand(source string) {
+	name, argument, _ := strings.Cut(strings.TrimSpace(source), " ")
+	argument = strings.TrimSpace(argument)
+
+	for _, command := range Commands {
+		if command.Name == name {
+			command.Run(l, argument)
+			return
+		}
+	}
+
+	fmt.Fprintf(l.Writer, "unknown command '%s'; available commands:\n", name)
+	for _, command := range Commands {
+		usage := strings.TrimSpace(command.Name + " " + command.Args)
+		fmt.Fprintf(l.Writer, "  %-16s %s\n", usage, command.Help)
+	}
+}
+
+func (l *Lox) Load(path string) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(l.Writer, err)
+		return
+	}
+
+	source := string(bytes)
+	err = l.Run(source)
+	if err != nil {
+		l.Diagnostics.Print(path, source, err)
+	}
+}
+
+// Env lists the variables in each scope from the innermost out, sorted by
+// name within a scope.
+func (l *Lox) Env(argument string) {
+	if l.Backend == "vm" {
+		l.PrintValues(l.VM.Globals)
+		return
+	}
+
+	for environment := l.Interpreter.Env; environment != nil; environment = environment.Enclosing {
+		l.PrintValues(environment.Values)
+	}
+}
+
+func (l *Lox) PrintValues(values map[string]interface{}) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(l.Writer, "%s = %s\n", name, interpreter.Describe(values[name]))
+	}
+}
+
+// Reset replaces both backends, keeping how they were configured.
+func (l *Lox) Reset(argument string) {
+	fresh := interpreter.NewInterpreter()
+	fresh.Writer = l.Interpreter.Writer
+	fresh.Heap.Limit = l.Interpreter.Heap.Limit
+	l.Interpreter = fresh
+
+	machine := vm.NewVM()
+	machine.Writer = l.VM.Writer
+	l.VM = machine
+}
+
+// Ast prints the tree for statements, or for an expression written without a
+// trailing ';'.
+func (l *Lox) Ast(source string) {
+	statements, err := l.Parse(source)
+	if err != nil {
+		expression, expressionErr := l.Parse(source + ";")
+		if expressionErr != nil {
+			l.Diagnostics.Print("<stdin>", source, err)
+			return
+		}
+		statements = expression
+	}
+
+	printer := ast.Printer{}
+	if len(statements) == 1 {
+		statement, ok := statements[0].(ast.ExpressionStatement)
+		if ok {
+			fmt.Fprint(l.Writer, printer.PrintExpression(statement.Expr))
+			return
+		}
+	}
+	fmt.Fprint(l.Writer, printer.Print(statements))
+}
+
+func (l *Lox) Tokens(source string) {
+	tokens, err := scanner.Scan(source)
+	for _, t := range tokens {
+		position := fmt.Sprintf("%d:%d", t.Line, t.Column)
+		line := fmt.Sprintf("%-6s %-14s %s", position, t.Type, t.Lexeme)
+		if t.Literal != nil {
+			line += fmt.Sprintf(" (%s)", interpreter.Describe(t.Literal))
+		}
+		fmt.Fprintln(l.Writer, strings.TrimRight(line, " "))
+	}
+
+	if err != nil {
+		l.Diagnostics.Print("<stdin>", source, err)
+	}
+}
+
+// Time runs source like any other input and reports the time taken to parse
+// and run it.
+func (l *Lox) Time(source string) {
+	start := time.Now()
+	err := l.RunInput(source)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		l.Diagnostics.Print("<stdin>", source, err)
+	}
+	fmt.Fprintf(l.Writer, "took %s\n", elapsed)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"golox/pkg/lox/diagnostics"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func session() (*Lox, *bytes.Buffer) {
+	var out bytes.Buffer
+	lox := NewLox()
+	lox.Writer = &out
+	lox.Interpreter.Writer = &out
+	lox.VM.Writer = &out
+	lox.Diagnostics = diagnostics.NewPrinter(&out)
+	return lox, &out
+}
+
+func TestRunCommand_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.lox")
+	os.WriteFile(path, []byte("fun twice(n) { return n * 2; }\nprint \"loaded\";\n"), 0o644)
+
+	lox, out := session()
+	lox.RunCommand(":load " + path)
+	err := lox.RunInput("twice(21);")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "loaded\n42\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestRunCommand_EnvAndReset(t *testing.T) {
+	lox, out := session()
+	lox.RunInput("var name = \"lox\"; var n = 1;")
+
+	lox.RunCommand(":env")
+	if out.String() != "clock = <native fn>\nn = 1\nname = \"lox\"\n" {
+		t.Fatalf("unexpected environment %q", out.String())
+	}
+
+	out.Reset()
+	lox.RunCommand(":reset")
+	lox.RunCommand(":env")
+	if out.String() != "clock = <native fn>\n" {
+		t.Fatalf("expected a fresh environment, got %q", out.String())
+	}
+
+	out.Reset()
+	lox.RunInput("print 1;")
+	if out.String() != "1\n" {
+		t.Fatal("expected output to still be captured after a reset")
+	}
+}
+
+func TestRunCommand_Ast(t *testing.T) {
+	lox, out := session()
+
+	lox.RunCommand(":ast -a + 1")
+	if out.String() != "(binary +\n  (unary -\n    (variable a))\n  (literal 1))\n" {
+		t.Fatalf("unexpected tree %q", out.String())
+	}
+
+	out.Reset()
+	lox.RunCommand(":ast print 1;")
+	if out.String() != "(print\n  (literal 1))\n" {
+		t.Fatalf("unexpected tree %q", out.String())
+	}
+}
+
+func TestRunCommand_Tokens(t *testing.T) {
+	lox, out := session()
+	lox.RunCommand(":tokens x = 1;")
+
+	expected := "1:1    IDENTIFIER     x\n" +
+		"1:3    EQUAL          =\n" +
+		"1:5    NUMBER         1 (1)\n" +
+		"1:6    SEMICOLON      ;\n" +
+		"1:7    EOF\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected tokens %q", out.String())
+	}
+}
+
+func TestRunCommand_Time(t *testing.T) {
+	lox, out := session()
+	lox.RunCommand(":time 1 + 2;")
+
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "3" || !strings.HasPrefix(lines[1], "took ") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	lox, out := session()
+	lox.RunCommand(":nope")
+
+	if !strings.HasPrefix(out.String(), "unknown command ':nope'") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	for _, command := range Commands {
+		if !strings.Contains(out.String(), command.Name) {
+			t.Fatalf("expected %s to be listed", command.Name)
+		}
+	}
+}
@@ -1,63 +1,297 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/cache"
+	"golox/pkg/lox/compiler"
+	"golox/pkg/lox/dap"
+	"golox/pkg/lox/debugger"
+	"golox/pkg/lox/diagnostics"
+	"golox/pkg/lox/editor"
 	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/optimizer"
 	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
 	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/vm"
+	"io"
 	"log"
 	"os"
 )
 
 type Lox struct {
+	Backend     string
 	Interpreter *interpreter.Interpreter
+	VM          *vm.VM
+	Diagnostics *diagnostics.Printer
+	Cache       *cache.Cache
+	DumpOpt     bool
+	Writer      io.Writer
 }
 
 func NewLox() *Lox {
-	return &Lox{
+	lox := &Lox{
+		Backend:     "tree",
 		Interpreter: interpreter.NewInterpreter(),
+		VM:          vm.NewVM(),
+		Diagnostics: diagnostics.NewPrinter(os.Stderr),
+		Writer:      os.Stdout,
 	}
+
+	dir, err := cache.DefaultDir()
+	if err == nil {
+		lox.Cache = cache.NewCache(dir)
+	}
+
+	return lox
 }
 
 func (l *Lox) Main(args []string) {
-	if len(args) > 2 {
-		log.Fatalln("usage: golox [script]")
-	} else if len(args) == 2 {
-		l.RunFile(args[1])
+	if len(args) > 1 && args[1] == "ast" {
+		l.PrintAst(args[1:])
+		return
+	}
+
+	if len(args) > 1 && args[1] == "debug" {
+		l.Debug(args[1:])
+		return
+	}
+
+	if len(args) > 1 && args[1] == "dap" {
+		l.ServeDAP(args[1:])
+		return
+	}
+
+	flags := flag.NewFlagSet("golox", flag.ExitOnError)
+	flags.StringVar(&l.Backend, "backend", "tree", "execution backend: 'tree' or 'vm'")
+	flags.IntVar(&l.Interpreter.Heap.Limit, "max-heap", 0, "abort when the tree backend's heap exceeds this many bytes (0 for no limit)")
+	noCache := flags.Bool("no-cache", false, "always parse scripts instead of using cached syntax trees")
+	flags.BoolVar(&l.DumpOpt, "dump-opt", false, "report the optimizer's rewrites on stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: golox [--backend=tree|vm] [--max-heap=bytes] [--no-cache] [--dump-opt] [script]")
+		fmt.Fprintln(os.Stderr, "       golox ast [--format=sexpr|json] script")
+		fmt.Fprintln(os.Stderr, "       golox debug script")
+		fmt.Fprintln(os.Stderr, "       golox dap")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if l.Backend != "tree" && l.Backend != "vm" {
+		log.Fatalf("unknown backend '%s'", l.Backend)
+	}
+
+	if l.Backend == "vm" && l.Interpreter.Heap.Limit != 0 {
+		log.Fatalln("--max-heap is only supported by the tree backend")
+	}
+
+	if *noCache {
+		l.Cache = nil
+	}
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(64)
+	} else if flags.NArg() == 1 {
+		l.RunFile(flags.Arg(0))
 	} else {
 		l.RunPrompt()
 	}
 }
 
-func (l *Lox) RunFile(path string) {
+// PrintAst implements 'golox ast', which prints the syntax tree parsed from a
+// script without running it.
+func (l *Lox) PrintAst(args []string) {
+	var format string
+	flags := flag.NewFlagSet("golox ast", flag.ExitOnError)
+	flags.StringVar(&format, "format", "sexpr", "output format: 'sexpr' or 'json'")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: golox ast [--format=sexpr|json] script")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(64)
+	}
+
+	path := flags.Arg(0)
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	l.Run(string(bytes))
-}
+	source := string(bytes)
+	statements, err := l.Parse(source)
+	if err != nil {
+		l.Diagnostics.Print(path, source, err)
+		os.Exit(1)
+	}
 
-func (l *Lox) RunPrompt() {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("> ")
-		line, err := reader.ReadString('\n')
+	printer := ast.Printer{}
+	switch format {
+	case "sexpr":
+		fmt.Print(printer.Print(statements))
+	case "json":
+		output, err := printer.JSON(statements)
 		if err != nil {
 			log.Fatalln(err)
 		}
-		l.Run(line)
+		fmt.Println(string(output))
+	default:
+		log.Fatalf("unknown format '%s'", format)
+	}
+}
+
+// Debug implements 'golox debug', which runs a script on the tree-walk
+// interpreter under an interactive debugger.
+func (l *Lox) Debug(args []string) {
+	flags := flag.NewFlagSet("golox debug", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: golox debug script")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(64)
+	}
+
+	path := flags.Arg(0)
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	source := string(bytes)
+	statements, err := l.Parse(source)
+	if err == nil {
+		var locals map[ast.Expression]int
+		locals, err = resolver.Resolve(statements)
+		l.Interpreter.Resolve(locals)
+	}
+	if err != nil {
+		l.Diagnostics.Print(path, source, err)
+		os.Exit(1)
+	}
+
+	console := debugger.NewConsole(editor.NewEditor(os.Stdin, os.Stdout), os.Stdout, path, source)
+	session := debugger.NewDebugger(l.Interpreter, console)
+	session.StopOnEntry = true
+
+	err = session.Run(statements)
+	if err == debugger.ErrQuit {
+		return
+	}
+	if err != nil {
+		l.Diagnostics.Print(path, source, err)
+		os.Exit(1)
+	}
+	fmt.Println("program finished")
+}
+
+// ServeDAP implements 'golox dap', which serves the Debug Adapter Protocol on
+// stdin and stdout so that an editor can debug scripts with golox.
+func (l *Lox) ServeDAP(args []string) {
+	flags := flag.NewFlagSet("golox dap", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: golox dap")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(64)
+	}
+
+	err := dap.NewServer(os.Stdin, os.Stdout).Serve()
+	if err != nil {
+		log.Fatalln(err)
 	}
 }
 
-func (l *Lox) Run(source string) {
-	tokens := scanner.Scan(source)
-	statements := parser.Parse(tokens)
+func (l *Lox) RunFile(path string) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	source := string(bytes)
+	statements, err := l.ParseCached(source)
+	if err == nil {
+		err = l.Execute(statements)
+	}
+	if err != nil {
+		l.Diagnostics.Print(path, source, err)
+		os.Exit(1)
+	}
+}
+
+func (l *Lox) Parse(source string) ([]ast.Statement, error) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.Parse(tokens)
+}
 
-	for _, statement := range statements {
-		statement.Accept(l.Interpreter)
+// ParseCached is Parse backed by the syntax tree cache. A tree that can't be
+// cached is still returned; the cache only ever saves work.
+func (l *Lox) ParseCached(source string) ([]ast.Statement, error) {
+	if l.Cache == nil {
+		return l.Parse(source)
 	}
+
+	statements, ok := l.Cache.Load(source)
+	if ok {
+		return statements, nil
+	}
+
+	statements, err := l.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	l.Cache.Store(source, statements)
+	return statements, nil
+}
+
+func (l *Lox) Run(source string) error {
+	statements, err := l.Parse(source)
+	if err != nil {
+		return err
+	}
+	return l.Execute(statements)
+}
+
+func (l *Lox) Execute(statements []ast.Statement) error {
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		return err
+	}
+
+	statements, changes := optimizer.Optimize(statements)
+	if l.DumpOpt {
+		for _, change := range changes {
+			fmt.Fprintln(os.Stderr, change)
+		}
+	}
+
+	if l.Backend == "vm" {
+		function, err := compiler.Compile(statements)
+		if err != nil {
+			return err
+		}
+		return l.VM.Interpret(function)
+	}
+
+	l.Interpreter.Resolve(locals)
+	return l.Interpreter.Interpret(statements)
 }
 
 func main() {
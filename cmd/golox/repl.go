@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/editor"
+	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RunPrompt reads and runs input until EOF. An error is reported and the
+// session carries on, so everything defined before it is still there.
+func (l *Lox) RunPrompt() {
+	lines := editor.NewEditor(os.Stdin, os.Stdout)
+	lines.Complete = l.Complete
+	path, err := HistoryPath()
+	if err == nil {
+		lines.LoadHistory(path)
+	}
+
+	for {
+		source, ok := ReadInput(lines)
+		if !ok {
+			fmt.Println()
+			return
+		}
+
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+
+		if IsCommand(source) {
+			l.RunCommand(source)
+			continue
+		}
+
+		err := l.RunInput(source)
+		if err != nil {
+			l.Diagnostics.Print("<stdin>", source, err)
+		}
+	}
+}
+
+// HistoryPath is where the prompt's history is kept between sessions.
+func HistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".golox_history"), nil
+}
+
+// ReadInput reads one line of input, and more lines for as long as the input
+// so far leaves a brace, parenthesis or string open. Ctrl-C abandons the
+// input, and it reports false once there is nothing left to read.
+func ReadInput(lines *editor.Editor) (string, bool) {
+	prompt := "> "
+
+	var source strings.Builder
+	for {
+		line, err := lines.ReadLine(prompt)
+		if errors.Is(err, editor.ErrInterrupted) {
+			return "", true
+		}
+		if err != nil {
+			return source.String(), source.Len() > 0
+		}
+
+		source.WriteString(line)
+		source.WriteString("\n")
+		if !IsIncomplete(source.String()) {
+			return source.String(), true
+		}
+		prompt = "... "
+	}
+}
+
+// Complete lists the keywords and defined names that start with prefix.
+func (l *Lox) Complete(prefix string) []string {
+	names := make([]string, 0)
+	for keyword := range token.Keywords {
+		names = append(names, keyword)
+	}
+
+	if l.Backend == "vm" {
+		for name := range l.VM.Globals {
+			names = append(names, name)
+		}
+	} else {
+		names = append(names, l.Interpreter.Env.Names()...)
+	}
+
+	matches := make([]string, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !slices.Contains(matches, name) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// IsIncomplete reports whether source ends inside an unclosed brace,
+// parenthesis or string.
+func IsIncomplete(source string) bool {
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+
+	for _, err := range s.Errors {
+		scanError, ok := err.(*scanner.ScanError)
+		if ok && scanError.Message == "unterminated string" {
+			return true
+		}
+	}
+
+	depth := 0
+	for _, t := range tokens {
+		switch t.Type {
+		case token.LEFT_PAREN, token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACE:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// RunInput is Run for input typed at the prompt, which also prints the value
+// of each bare expression statement. As with :ast, an expression may be
+// typed without its closing ';'.
+func (l *Lox) RunInput(source string) error {
+	statements, err := l.Parse(source)
+	if err != nil {
+		expression, expressionErr := l.Parse(source + ";")
+		if expressionErr != nil {
+			return err
+		}
+		statements = expression
+	}
+	return l.Execute(Echo(statements))
+}
+
+// Echo turns top-level expression statements into print statements.
+// Assignments are deliberately not echoed, so that 'a = 1;' runs quietly.
+func Echo(statements []ast.Statement) []ast.Statement {
+	echoed := make([]ast.Statement, 0, len(statements))
+	for _, statement := range statements {
+		expression, ok := statement.(ast.ExpressionStatement)
+		if ok && !IsAssignment(expression.Expr) {
+			statement = ast.Print{
+				Expr:  expression.Expr,
+				Range: expression.Range,
+			}
+		}
+		echoed = append(echoed, statement)
+	}
+	return echoed
+}
+
+func IsAssignment(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.Assignment, ast.Set:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/editor"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	sources := map[string]bool{
+		"print 1;":                  false,
+		"fun f() {":                 true,
+		"fun f() {\n  return 1;\n}": false,
+		"print (1 +":                true,
+		"print \"a":                 true,
+		"print \"{\";":              false,
+		"// {":                      false,
+		"}":                         false,
+	}
+
+	for source, expected := range sources {
+		if IsIncomplete(source) != expected {
+			t.Fatalf("expected IsIncomplete(%q) to be %v", source, expected)
+		}
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	lines := editor.NewEditor(strings.NewReader("fun f() {\n  return 1;\n}\nprint f();"), io.Discard)
+
+	source, ok := ReadInput(lines)
+	if !ok || source != "fun f() {\n  return 1;\n}\n" {
+		t.Fatalf("expected the whole function, got %q", source)
+	}
+
+	source, ok = ReadInput(lines)
+	if !ok || source != "print f();\n" {
+		t.Fatalf("expected the next line, got %q", source)
+	}
+
+	_, ok = ReadInput(lines)
+	if ok {
+		t.Fatal("expected no more input")
+	}
+}
+
+func TestEcho(t *testing.T) {
+	lox := NewLox()
+	statements, err := lox.Parse("1 + 2; a = 1; a.b = 2; print 3;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements = Echo(statements)
+
+	if _, ok := statements[0].(ast.Print); !ok {
+		t.Fatal("expected the expression to be printed")
+	}
+
+	for _, statement := range statements[1:3] {
+		if _, ok := statement.(ast.ExpressionStatement); !ok {
+			t.Fatal("expected the assignment to be left alone")
+		}
+	}
+}
+
+func TestRunInput_BareExpression(t *testing.T) {
+	lox, out := session()
+	lox.RunInput("var a = 1;")
+
+	err := lox.RunInput("a + 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "2\n" {
+		t.Fatalf("expected the expression to be echoed, got %q", out.String())
+	}
+
+	err = lox.RunInput("a +\n")
+	if err == nil || !strings.Contains(err.Error(), "expect expression") {
+		t.Fatalf("expected the original parse error, got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	lox := NewLox()
+	err := lox.Run("var counter = 0; fun count() {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := lox.Complete("co")
+	expected := []string{"continue", "count", "counter"}
+	if strings.Join(matches, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v, got %v", expected, matches)
+	}
+
+	matches = lox.Complete("cl")
+	if strings.Join(matches, " ") != "class clock" {
+		t.Fatalf("expected the keyword and the builtin, got %v", matches)
+	}
+}
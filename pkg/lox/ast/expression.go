@@ -4,101 +4,177 @@ import (
 	"golox/pkg/lox/token"
 )
 
+// Expressions that refer to a binding (Assignment, Super, This and Variable)
+// use pointer receivers so the resolver can key scope depths on node identity.
 type Expression interface {
 	Accept(v Visitor) interface{}
+	Span() token.Span
 }
 
 type Assignment struct {
 	Name  token.Token
 	Value Expression
+	Range token.Span
 }
 
-func (expr Assignment) Accept(v Visitor) interface{} {
-	return v.VisitAssignment(&expr)
+func (expr *Assignment) Accept(v Visitor) interface{} {
+	return v.VisitAssignment(expr)
+}
+
+func (expr *Assignment) Span() token.Span {
+	return expr.Range
 }
 
 type Binary struct {
 	Operation token.Token
 	Left      Expression
 	Right     Expression
+	Range     token.Span
 }
 
 func (expr Binary) Accept(v Visitor) interface{} {
 	return v.VisitBinary(&expr)
 }
 
+func (expr Binary) Span() token.Span {
+	return expr.Range
+}
+
 type Call struct {
 	Callee    Expression
+	Paren     token.Token
 	Arguments []Expression
+	Range     token.Span
 }
 
 func (expr Call) Accept(v Visitor) interface{} {
 	return v.VisitCall(&expr)
 }
 
-type Get struct{}
+func (expr Call) Span() token.Span {
+	return expr.Range
+}
+
+type Get struct {
+	Object Expression
+	Name   token.Token
+	Range  token.Span
+}
 
 func (expr Get) Accept(v Visitor) interface{} {
 	return v.VisitGet(&expr)
 }
 
+func (expr Get) Span() token.Span {
+	return expr.Range
+}
+
 type Grouping struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 func (expr Grouping) Accept(v Visitor) interface{} {
 	return v.VisitGrouping(&expr)
 }
 
+func (expr Grouping) Span() token.Span {
+	return expr.Range
+}
+
 type Literal struct {
 	Value interface{}
+	Range token.Span
 }
 
 func (expr Literal) Accept(v Visitor) interface{} {
 	return v.VisitLiteral(&expr)
 }
 
+func (expr Literal) Span() token.Span {
+	return expr.Range
+}
+
 type Logical struct {
 	Operation token.Token
 	Left      Expression
 	Right     Expression
+	Range     token.Span
 }
 
 func (expr Logical) Accept(v Visitor) interface{} {
 	return v.VisitLogical(&expr)
 }
 
-type Set struct{}
+func (expr Logical) Span() token.Span {
+	return expr.Range
+}
+
+type Set struct {
+	Object Expression
+	Name   token.Token
+	Value  Expression
+	Range  token.Span
+}
 
 func (expr Set) Accept(v Visitor) interface{} {
 	return v.VisitSet(&expr)
 }
 
-type Super struct{}
+func (expr Set) Span() token.Span {
+	return expr.Range
+}
+
+type Super struct {
+	Keyword token.Token
+	Method  token.Token
+	Range   token.Span
+}
+
+func (expr *Super) Accept(v Visitor) interface{} {
+	return v.VisitSuper(expr)
+}
+
+func (expr *Super) Span() token.Span {
+	return expr.Range
+}
 
-func (expr Super) Accept(v Visitor) interface{} {
-	return v.VisitSuper(&expr)
+type This struct {
+	Keyword token.Token
+	Range   token.Span
 }
 
-type This struct{}
+func (expr *This) Accept(v Visitor) interface{} {
+	return v.VisitThis(expr)
+}
 
-func (expr This) Accept(v Visitor) interface{} {
-	return v.VisitThis(&expr)
+func (expr *This) Span() token.Span {
+	return expr.Range
 }
 
 type Unary struct {
 	Operation token.Token
 	Operand   Expression
+	Range     token.Span
 }
 
 func (expr Unary) Accept(v Visitor) interface{} {
 	return v.VisitUnary(&expr)
 }
 
+func (expr Unary) Span() token.Span {
+	return expr.Range
+}
+
 type Variable struct {
-	Name token.Token
+	Name  token.Token
+	Range token.Span
+}
+
+func (expr *Variable) Accept(v Visitor) interface{} {
+	return v.VisitVariable(expr)
 }
 
-func (expr Variable) Accept(v Visitor) interface{} {
-	return v.VisitVariable(&expr)
+func (expr *Variable) Span() token.Span {
+	return expr.Range
 }
@@ -0,0 +1,217 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"golox/pkg/lox/token"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Node is a generic view of a syntax tree node, used to print trees for
+// debugging. Name holds whatever identifies the node beyond its type: an
+// identifier, an operator or a literal value.
+type Node struct {
+	Type     string     `json:"type"`
+	Name     string     `json:"name,omitempty"`
+	Params   []string   `json:"params,omitempty"`
+	Span     token.Span `json:"span"`
+	Children []*Node    `json:"children,omitempty"`
+}
+
+// Printer renders syntax trees as indented S-expressions or as JSON.
+type Printer struct{}
+
+func (p Printer) Print(statements []Statement) string {
+	var b strings.Builder
+	for _, node := range p.Nodes(statements) {
+		writeNode(&b, node, 0)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (p Printer) PrintExpression(expr Expression) string {
+	var b strings.Builder
+	writeNode(&b, p.accept(expr), 0)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (p Printer) JSON(statements []Statement) ([]byte, error) {
+	return json.MarshalIndent(p.Nodes(statements), "", "  ")
+}
+
+func (p Printer) Nodes(statements []Statement) []*Node {
+	nodes := make([]*Node, 0, len(statements))
+	for _, statement := range statements {
+		nodes = append(nodes, statement.Accept(p).(*Node))
+	}
+	return nodes
+}
+
+func writeNode(b *strings.Builder, node *Node, depth int) {
+	b.WriteString("(" + strings.ToLower(node.Type))
+	if node.Name != "" {
+		b.WriteString(" " + node.Name)
+	}
+	if node.Params != nil {
+		b.WriteString(" (" + strings.Join(node.Params, " ") + ")")
+	}
+	for _, child := range node.Children {
+		b.WriteString("\n" + strings.Repeat("  ", depth+1))
+		writeNode(b, child, depth+1)
+	}
+	b.WriteString(")")
+}
+
+// accept returns the node for a child, or nil for a missing optional child
+// such as an else branch.
+func (p Printer) accept(child interface{ Accept(v Visitor) interface{} }) *Node {
+	if child == nil {
+		return nil
+	}
+	return child.Accept(p).(*Node)
+}
+
+func newNode(kind string, name string, span token.Span, children ...*Node) *Node {
+	node := &Node{
+		Type: kind,
+		Name: name,
+		Span: span,
+	}
+	for _, child := range children {
+		if child != nil {
+			node.Children = append(node.Children, child)
+		}
+	}
+	return node
+}
+
+func formatLiteral(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(value)
+	case float64:
+		text := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eIN") {
+			text += ".0"
+		}
+		return text
+	case *big.Int:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (p Printer) VisitAssignment(expr *Assignment) interface{} {
+	return newNode("Assignment", expr.Name.Lexeme, expr.Range, p.accept(expr.Value))
+}
+
+func (p Printer) VisitBinary(expr *Binary) interface{} {
+	return newNode("Binary", expr.Operation.Lexeme, expr.Range, p.accept(expr.Left), p.accept(expr.Right))
+}
+
+func (p Printer) VisitCall(expr *Call) interface{} {
+	node := newNode("Call", "", expr.Range, p.accept(expr.Callee))
+	for _, argument := range expr.Arguments {
+		node.Children = append(node.Children, p.accept(argument))
+	}
+	return node
+}
+
+func (p Printer) VisitGet(expr *Get) interface{} {
+	return newNode("Get", expr.Name.Lexeme, expr.Range, p.accept(expr.Object))
+}
+
+func (p Printer) VisitGrouping(expr *Grouping) interface{} {
+	return newNode("Grouping", "", expr.Range, p.accept(expr.Expr))
+}
+
+func (p Printer) VisitLiteral(expr *Literal) interface{} {
+	return newNode("Literal", formatLiteral(expr.Value), expr.Range)
+}
+
+func (p Printer) VisitLogical(expr *Logical) interface{} {
+	return newNode("Logical", expr.Operation.Lexeme, expr.Range, p.accept(expr.Left), p.accept(expr.Right))
+}
+
+func (p Printer) VisitSet(expr *Set) interface{} {
+	return newNode("Set", expr.Name.Lexeme, expr.Range, p.accept(expr.Object), p.accept(expr.Value))
+}
+
+func (p Printer) VisitSuper(expr *Super) interface{} {
+	return newNode("Super", expr.Method.Lexeme, expr.Range)
+}
+
+func (p Printer) VisitThis(expr *This) interface{} {
+	return newNode("This", "", expr.Range)
+}
+
+func (p Printer) VisitUnary(expr *Unary) interface{} {
+	return newNode("Unary", expr.Operation.Lexeme, expr.Range, p.accept(expr.Operand))
+}
+
+func (p Printer) VisitVariable(expr *Variable) interface{} {
+	return newNode("Variable", expr.Name.Lexeme, expr.Range)
+}
+
+func (p Printer) VisitBlock(stmt *Block) interface{} {
+	return newNode("Block", "", stmt.Range, p.Nodes(stmt.Statements)...)
+}
+
+func (p Printer) VisitBreak(stmt *Break) interface{} {
+	return newNode("Break", "", stmt.Range)
+}
+
+func (p Printer) VisitClass(stmt *Class) interface{} {
+	node := newNode("Class", stmt.Name.Lexeme, stmt.Range)
+	if stmt.Superclass != nil {
+		node.Children = append(node.Children, p.accept(stmt.Superclass))
+	}
+	for index := range stmt.Methods {
+		node.Children = append(node.Children, p.accept(&stmt.Methods[index]))
+	}
+	return node
+}
+
+func (p Printer) VisitContinue(stmt *Continue) interface{} {
+	return newNode("Continue", "", stmt.Range)
+}
+
+func (p Printer) VisitExpressionStatement(stmt *ExpressionStatement) interface{} {
+	return newNode("Expression", "", stmt.Range, p.accept(stmt.Expr))
+}
+
+func (p Printer) VisitFunction(stmt *Function) interface{} {
+	node := newNode("Function", stmt.Name.Lexeme, stmt.Range, p.Nodes(stmt.Body)...)
+	node.Params = make([]string, 0, len(stmt.Params))
+	for _, param := range stmt.Params {
+		node.Params = append(node.Params, param.Lexeme)
+	}
+	return node
+}
+
+func (p Printer) VisitIf(stmt *If) interface{} {
+	return newNode("If", "", stmt.Range, p.accept(stmt.Condition), p.accept(stmt.ThenBranch), p.accept(stmt.ElseBranch))
+}
+
+func (p Printer) VisitPrint(stmt *Print) interface{} {
+	return newNode("Print", "", stmt.Range, p.accept(stmt.Expr))
+}
+
+func (p Printer) VisitReturn(stmt *Return) interface{} {
+	return newNode("Return", "", stmt.Range, p.accept(stmt.Value))
+}
+
+func (p Printer) VisitVar(stmt *Var) interface{} {
+	return newNode("Var", stmt.Name.Lexeme, stmt.Range, p.accept(stmt.Initializer))
+}
+
+func (p Printer) VisitWhile(stmt *While) interface{} {
+	return newNode("While", "", stmt.Range, p.accept(stmt.Condition), p.accept(stmt.Body), p.accept(stmt.Increment))
+}
@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"encoding/json"
+	"golox/pkg/lox/token"
+	"testing"
+)
+
+func TestPrinter_Print(t *testing.T) {
+	statements := []Statement{
+		Var{
+			Name: token.Token{Type: token.IDENTIFIER, Lexeme: "a"},
+			Initializer: Binary{
+				Operation: token.Token{Type: token.PLUS, Lexeme: "+"},
+				Left:      Literal{Value: int64(1)},
+				Right:     Literal{Value: 2.0},
+			},
+		},
+		If{
+			Condition:  &Variable{Name: token.Token{Type: token.IDENTIFIER, Lexeme: "a"}},
+			ThenBranch: Print{Expr: Literal{Value: "yes"}},
+		},
+		Function{
+			Name: token.Token{Type: token.IDENTIFIER, Lexeme: "f"},
+			Body: []Statement{Return{}},
+		},
+	}
+
+	expected := `(var a
+  (binary +
+    (literal 1)
+    (literal 2.0)))
+(if
+  (variable a)
+  (print
+    (literal "yes")))
+(function f ()
+  (return))
+`
+	output := Printer{}.Print(statements)
+	if output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestPrinter_JSON(t *testing.T) {
+	span := token.Span{
+		Start: token.Position{Line: 1, Column: 1, Offset: 0},
+		End:   token.Position{Line: 1, Column: 7, Offset: 6},
+	}
+	statements := []Statement{
+		Print{Expr: Literal{Value: nil, Range: span}, Range: span},
+	}
+
+	output, err := Printer{}.JSON(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nodes []Node
+	err = json.Unmarshal(output, &nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 1 || nodes[0].Type != "Print" || nodes[0].Span != span {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+
+	if len(nodes[0].Children) != 1 || nodes[0].Children[0].Type != "Literal" || nodes[0].Children[0].Name != "nil" {
+		t.Fatalf("unexpected children %+v", nodes[0].Children)
+	}
+}
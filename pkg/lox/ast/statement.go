@@ -6,83 +6,161 @@ import (
 
 type Statement interface {
 	Accept(v Visitor) interface{}
+	Span() token.Span
 }
 
 type Block struct {
 	Statements []Statement
+	Range      token.Span
 }
 
 func (stmt Block) Accept(v Visitor) interface{} {
 	return v.VisitBlock(&stmt)
 }
 
+func (stmt Block) Span() token.Span {
+	return stmt.Range
+}
+
+type Break struct {
+	Keyword token.Token
+	Range   token.Span
+}
+
+func (stmt Break) Accept(v Visitor) interface{} {
+	return v.VisitBreak(&stmt)
+}
+
+func (stmt Break) Span() token.Span {
+	return stmt.Range
+}
+
 type Class struct {
-	Superclass Variable
+	Name       token.Token
+	Superclass *Variable
 	Methods    []Function
+	Range      token.Span
 }
 
 func (stmt Class) Accept(v Visitor) interface{} {
 	return v.VisitClass(&stmt)
 }
 
+func (stmt Class) Span() token.Span {
+	return stmt.Range
+}
+
+type Continue struct {
+	Keyword token.Token
+	Range   token.Span
+}
+
+func (stmt Continue) Accept(v Visitor) interface{} {
+	return v.VisitContinue(&stmt)
+}
+
+func (stmt Continue) Span() token.Span {
+	return stmt.Range
+}
+
 type ExpressionStatement struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 func (stmt ExpressionStatement) Accept(v Visitor) interface{} {
 	return v.VisitExpressionStatement(&stmt)
 }
 
+func (stmt ExpressionStatement) Span() token.Span {
+	return stmt.Range
+}
+
 type Function struct {
 	Name   token.Token
 	Params []token.Token
 	Body   []Statement
+	Range  token.Span
 }
 
 func (stmt Function) Accept(v Visitor) interface{} {
 	return v.VisitFunction(&stmt)
 }
 
+func (stmt Function) Span() token.Span {
+	return stmt.Range
+}
+
 type If struct {
 	Condition  Expression
 	ThenBranch Statement
 	ElseBranch Statement
+	Range      token.Span
 }
 
 func (stmt If) Accept(v Visitor) interface{} {
 	return v.VisitIf(&stmt)
 }
 
+func (stmt If) Span() token.Span {
+	return stmt.Range
+}
+
 type Print struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 func (stmt Print) Accept(v Visitor) interface{} {
 	return v.VisitPrint(&stmt)
 }
 
+func (stmt Print) Span() token.Span {
+	return stmt.Range
+}
+
 type Return struct {
-	Value Expression
+	Keyword token.Token
+	Value   Expression
+	Range   token.Span
 }
 
 func (stmt Return) Accept(v Visitor) interface{} {
 	return v.VisitReturn(&stmt)
 }
 
+func (stmt Return) Span() token.Span {
+	return stmt.Range
+}
+
 type Var struct {
 	Name        token.Token
 	Initializer Expression
+	Range       token.Span
 }
 
 func (stmt Var) Accept(v Visitor) interface{} {
 	return v.VisitVar(&stmt)
 }
 
+func (stmt Var) Span() token.Span {
+	return stmt.Range
+}
+
+// While is also what 'for' loops desugar to. Increment holds the for loop's
+// increment clause, which runs after the body even when it ends in
+// 'continue'; it is nil for while loops.
 type While struct {
 	Condition Expression
 	Body      Statement
+	Increment Expression
+	Range     token.Span
 }
 
 func (stmt While) Accept(v Visitor) interface{} {
 	return v.VisitWhile(&stmt)
 }
+
+func (stmt While) Span() token.Span {
+	return stmt.Range
+}
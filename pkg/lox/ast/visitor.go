@@ -14,7 +14,9 @@ type Visitor interface {
 	VisitUnary(expr *Unary) interface{}
 	VisitVariable(expr *Variable) interface{}
 	VisitBlock(stmt *Block) interface{}
+	VisitBreak(stmt *Break) interface{}
 	VisitClass(stmt *Class) interface{}
+	VisitContinue(stmt *Continue) interface{}
 	VisitExpressionStatement(stmt *ExpressionStatement) interface{}
 	VisitFunction(stmt *Function) interface{}
 	VisitIf(stmt *If) interface{}
@@ -0,0 +1,94 @@
+// Package cache stores parsed syntax trees on disk so that running an
+// unchanged script can skip scanning and parsing.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"golox/pkg/lox/ast"
+	"os"
+	"path/filepath"
+)
+
+// Cache is a directory of encoded trees named after the SHA-256 hash of the
+// source they were parsed from. Each file repeats the hash ahead of the
+// encoded tree, so a file that was renamed or partly written is never mistaken
+// for another source's tree.
+type Cache struct {
+	Dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{
+		Dir: dir,
+	}
+}
+
+// DefaultDir is the golox directory inside the user's cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "golox"), nil
+}
+
+func Hash(source string) []byte {
+	sum := sha256.Sum256([]byte(source))
+	return sum[:]
+}
+
+func (c *Cache) Path(source string) string {
+	return filepath.Join(c.Dir, hex.EncodeToString(Hash(source))+".ast")
+}
+
+// Load returns the cached tree for source. It reports false when there is no
+// usable entry, including when the file is stale or from an incompatible
+// version, in which case the caller should parse and Store again.
+func (c *Cache) Load(source string) ([]ast.Statement, bool) {
+	data, err := os.ReadFile(c.Path(source))
+	if err != nil {
+		return nil, false
+	}
+
+	hash := Hash(source)
+	if !bytes.HasPrefix(data, hash) {
+		return nil, false
+	}
+
+	statements, err := Decode(data[len(hash):])
+	if err != nil {
+		return nil, false
+	}
+	return statements, true
+}
+
+// Store writes the tree for source, replacing any existing entry. The file is
+// written under a temporary name and renamed into place so that concurrent
+// runs never read a partial entry.
+func (c *Cache) Store(source string, statements []ast.Statement) error {
+	err := os.MkdirAll(c.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(c.Dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(append(Hash(source), Encode(statements)...))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), c.Path(source))
+}
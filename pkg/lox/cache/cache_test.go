@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/scanner"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const source = `
+var a = 1 + 2.5 * -3 % 4;
+var big = 99999999999999999999;
+var s = "string\nwith newline";
+var b = nil == false and !true or (a >= 1);
+class A {
+  init(x) { this.x = x; }
+  get() { return this.x; }
+}
+class B < A {
+  get() { return super.get() + 1; }
+}
+fun f(a, b) {
+  if (a < b) return a; else return;
+}
+for (var i = 0; i < 3; i = i + 1) {
+  if (i == 1) continue;
+  print f(i, 2);
+}
+while (false) { break; }
+B(1).y = B(2).get();
+`
+
+func parse(t *testing.T, source string) []ast.Statement {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return statements
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	statements := parse(t, source)
+
+	decoded, err := Decode(Encode(statements))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(statements, decoded) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", ast.Printer{}.Print(statements), ast.Printer{}.Print(decoded))
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	data := Encode(parse(t, source))
+
+	// Every truncation must be reported rather than panic or decode quietly.
+	for i := 0; i < len(data); i++ {
+		_, err := Decode(data[:i])
+
+		var decodeError *DecodeError
+		if !errors.As(err, &decodeError) {
+			t.Fatalf("expected a 'DecodeError' decoding %d of %d bytes", i, len(data))
+		}
+	}
+
+	version := append([]byte(MAGIC), binary.AppendUvarint(nil, VERSION+1)...)
+	version = append(version, data[len(version):]...)
+	_, err := Decode(version)
+	if err == nil {
+		t.Fatal("expected an error decoding another version")
+	}
+}
+
+func TestCache_LoadStore(t *testing.T) {
+	cache := NewCache(t.TempDir())
+
+	_, ok := cache.Load(source)
+	if ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	statements := parse(t, source)
+	err := cache.Store(source, statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, ok := cache.Load(source)
+	if !ok {
+		t.Fatal("expected a hit after storing")
+	}
+
+	if !reflect.DeepEqual(statements, loaded) {
+		t.Fatal("expected the stored tree")
+	}
+
+	_, ok = cache.Load(source + "\nprint 1;")
+	if ok {
+		t.Fatal("expected a miss for changed source")
+	}
+}
+
+func TestCache_Incompatible(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	statements := parse(t, source)
+
+	entries := map[string][]byte{
+		"stale":        append(Hash("other source"), Encode(statements)...),
+		"incompatible": append(Hash(source), []byte("GLXA\x00")...),
+		"corrupt":      []byte("garbage"),
+	}
+
+	for name, data := range entries {
+		err := os.WriteFile(cache.Path(source), data, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, ok := cache.Load(source)
+		if ok {
+			t.Fatalf("expected a %s entry to be ignored", name)
+		}
+
+		err = cache.Store(source, statements)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, ok = cache.Load(source)
+		if !ok {
+			t.Fatalf("expected a %s entry to be rebuilt", name)
+		}
+	}
+}
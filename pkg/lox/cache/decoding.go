@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/token"
+	"math"
+	"math/big"
+)
+
+// Decode reads statements written by Encode. Data from another version of the
+// format, or that is truncated or corrupt, is reported as a *DecodeError.
+func Decode(data []byte) (statements []ast.Statement, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		decodeError, ok := r.(*DecodeError)
+		if !ok {
+			panic(r)
+		}
+
+		statements = nil
+		err = decodeError
+	}()
+
+	d := &Decoder{Data: data}
+	if string(d.ReadBytes(len(MAGIC))) != MAGIC {
+		d.Error("bad magic number")
+	}
+	if version := d.ReadUint(); version != VERSION {
+		d.Error(fmt.Sprintf("unsupported version %d", version))
+	}
+	if types := d.ReadUint(); types != tokenTypes {
+		d.Error(fmt.Sprintf("expected %d token types but got %d", tokenTypes, types))
+	}
+
+	statements = d.ReadStatements()
+	if d.Offset != len(d.Data) {
+		d.Error("trailing data")
+	}
+	return statements, nil
+}
+
+type Decoder struct {
+	Data   []byte
+	Offset int
+}
+
+func (d *Decoder) Error(message string) {
+	panic(&DecodeError{Message: message})
+}
+
+func (d *Decoder) ReadTag() byte {
+	if d.Offset >= len(d.Data) {
+		d.Error("unexpected end of data")
+	}
+	b := d.Data[d.Offset]
+	d.Offset++
+	return b
+}
+
+func (d *Decoder) Peek() byte {
+	if d.Offset >= len(d.Data) {
+		d.Error("unexpected end of data")
+	}
+	return d.Data[d.Offset]
+}
+
+func (d *Decoder) ReadBytes(n int) []byte {
+	if n < 0 || n > len(d.Data)-d.Offset {
+		d.Error("unexpected end of data")
+	}
+	b := d.Data[d.Offset : d.Offset+n]
+	d.Offset += n
+	return b
+}
+
+func (d *Decoder) ReadUint() uint64 {
+	value, n := binary.Uvarint(d.Data[d.Offset:])
+	if n <= 0 {
+		d.Error("bad varint")
+	}
+	d.Offset += n
+	return value
+}
+
+func (d *Decoder) ReadInt() int64 {
+	value, n := binary.Varint(d.Data[d.Offset:])
+	if n <= 0 {
+		d.Error("bad varint")
+	}
+	d.Offset += n
+	return value
+}
+
+// ReadLength reads a count of items that are each at least one byte long, so
+// corrupt data can't ask for more items than the input could hold.
+func (d *Decoder) ReadLength() int {
+	length := d.ReadUint()
+	if length > uint64(len(d.Data)-d.Offset) {
+		d.Error("length out of range")
+	}
+	return int(length)
+}
+
+func (d *Decoder) ReadString() string {
+	return string(d.ReadBytes(d.ReadLength()))
+}
+
+func (d *Decoder) ReadPosition() token.Position {
+	return token.Position{
+		Line:   int(d.ReadInt()),
+		Column: int(d.ReadInt()),
+		Offset: int(d.ReadInt()),
+	}
+}
+
+func (d *Decoder) ReadSpan() token.Span {
+	return token.Span{
+		Start: d.ReadPosition(),
+		End:   d.ReadPosition(),
+	}
+}
+
+func (d *Decoder) ReadValue() interface{} {
+	switch tag := d.ReadTag(); tag {
+	case valueNil:
+		return nil
+	case valueFalse:
+		return false
+	case valueTrue:
+		return true
+	case valueInteger:
+		return d.ReadInt()
+	case valueFloat:
+		return math.Float64frombits(binary.LittleEndian.Uint64(d.ReadBytes(8)))
+	case valueBigInteger:
+		value, ok := new(big.Int).SetString(d.ReadString(), 10)
+		if !ok {
+			d.Error("bad big integer")
+		}
+		return value
+	case valueString:
+		return d.ReadString()
+	default:
+		d.Error(fmt.Sprintf("unknown value tag %d", tag))
+		return nil
+	}
+}
+
+func (d *Decoder) ReadToken() token.Token {
+	tokenType := d.ReadUint()
+	if tokenType >= tokenTypes {
+		d.Error(fmt.Sprintf("unknown token type %d", tokenType))
+	}
+	return token.Token{
+		Type:    token.TokenType(tokenType),
+		Lexeme:  d.ReadString(),
+		Literal: d.ReadValue(),
+		Line:    int(d.ReadInt()),
+		Column:  int(d.ReadInt()),
+		Offset:  int(d.ReadInt()),
+	}
+}
+
+func (d *Decoder) ReadTokens() []token.Token {
+	tokens := make([]token.Token, d.ReadLength())
+	for i := range tokens {
+		tokens[i] = d.ReadToken()
+	}
+	return tokens
+}
+
+func (d *Decoder) ReadExpression() ast.Expression {
+	if tag := d.Peek(); tag >= tagBlock {
+		d.Error(fmt.Sprintf("expected an expression but got node tag %d", tag))
+	}
+	node := d.ReadNode()
+	if node == nil {
+		return nil
+	}
+	return node.(ast.Expression)
+}
+
+func (d *Decoder) ReadStatement() ast.Statement {
+	if tag := d.Peek(); tag != tagNil && tag < tagBlock {
+		d.Error(fmt.Sprintf("expected a statement but got node tag %d", tag))
+	}
+	node := d.ReadNode()
+	if node == nil {
+		return nil
+	}
+	return node.(ast.Statement)
+}
+
+func (d *Decoder) ReadExpressions() []ast.Expression {
+	expressions := make([]ast.Expression, d.ReadLength())
+	for i := range expressions {
+		expressions[i] = d.ReadExpression()
+	}
+	return expressions
+}
+
+func (d *Decoder) ReadStatements() []ast.Statement {
+	statements := make([]ast.Statement, d.ReadLength())
+	for i := range statements {
+		statements[i] = d.ReadStatement()
+	}
+	return statements
+}
+
+func (d *Decoder) ReadFunction() ast.Function {
+	return ast.Function{
+		Name:   d.ReadToken(),
+		Params: d.ReadTokens(),
+		Body:   d.ReadStatements(),
+		Range:  d.ReadSpan(),
+	}
+}
+
+// ReadNode reads one tagged node. Expression tags come before tagBlock and
+// statement tags from it onwards.
+func (d *Decoder) ReadNode() interface{} {
+	switch tag := d.ReadTag(); tag {
+	case tagNil:
+		return nil
+	case tagAssignment:
+		return &ast.Assignment{
+			Name:  d.ReadToken(),
+			Value: d.ReadExpression(),
+			Range: d.ReadSpan(),
+		}
+	case tagBinary:
+		return ast.Binary{
+			Operation: d.ReadToken(),
+			Left:      d.ReadExpression(),
+			Right:     d.ReadExpression(),
+			Range:     d.ReadSpan(),
+		}
+	case tagCall:
+		return ast.Call{
+			Callee:    d.ReadExpression(),
+			Paren:     d.ReadToken(),
+			Arguments: d.ReadExpressions(),
+			Range:     d.ReadSpan(),
+		}
+	case tagGet:
+		return ast.Get{
+			Object: d.ReadExpression(),
+			Name:   d.ReadToken(),
+			Range:  d.ReadSpan(),
+		}
+	case tagGrouping:
+		return ast.Grouping{
+			Expr:  d.ReadExpression(),
+			Range: d.ReadSpan(),
+		}
+	case tagLiteral:
+		return ast.Literal{
+			Value: d.ReadValue(),
+			Range: d.ReadSpan(),
+		}
+	case tagLogical:
+		return ast.Logical{
+			Operation: d.ReadToken(),
+			Left:      d.ReadExpression(),
+			Right:     d.ReadExpression(),
+			Range:     d.ReadSpan(),
+		}
+	case tagSet:
+		return ast.Set{
+			Object: d.ReadExpression(),
+			Name:   d.ReadToken(),
+			Value:  d.ReadExpression(),
+			Range:  d.ReadSpan(),
+		}
+	case tagSuper:
+		return &ast.Super{
+			Keyword: d.ReadToken(),
+			Method:  d.ReadToken(),
+			Range:   d.ReadSpan(),
+		}
+	case tagThis:
+		return &ast.This{
+			Keyword: d.ReadToken(),
+			Range:   d.ReadSpan(),
+		}
+	case tagUnary:
+		return ast.Unary{
+			Operation: d.ReadToken(),
+			Operand:   d.ReadExpression(),
+			Range:     d.ReadSpan(),
+		}
+	case tagVariable:
+		return &ast.Variable{
+			Name:  d.ReadToken(),
+			Range: d.ReadSpan(),
+		}
+	case tagBlock:
+		return ast.Block{
+			Statements: d.ReadStatements(),
+			Range:      d.ReadSpan(),
+		}
+	case tagClass:
+		class := ast.Class{
+			Name: d.ReadToken(),
+		}
+		if superclass := d.ReadExpression(); superclass != nil {
+			variable, ok := superclass.(*ast.Variable)
+			if !ok {
+				d.Error("expected a superclass variable")
+			}
+			class.Superclass = variable
+		}
+		class.Methods = make([]ast.Function, d.ReadLength())
+		for i := range class.Methods {
+			class.Methods[i] = d.ReadFunction()
+		}
+		class.Range = d.ReadSpan()
+		return class
+	case tagExpressionStatement:
+		return ast.ExpressionStatement{
+			Expr:  d.ReadExpression(),
+			Range: d.ReadSpan(),
+		}
+	case tagFunction:
+		return d.ReadFunction()
+	case tagIf:
+		return ast.If{
+			Condition:  d.ReadExpression(),
+			ThenBranch: d.ReadStatement(),
+			ElseBranch: d.ReadStatement(),
+			Range:      d.ReadSpan(),
+		}
+	case tagPrint:
+		return ast.Print{
+			Expr:  d.ReadExpression(),
+			Range: d.ReadSpan(),
+		}
+	case tagReturn:
+		return ast.Return{
+			Keyword: d.ReadToken(),
+			Value:   d.ReadExpression(),
+			Range:   d.ReadSpan(),
+		}
+	case tagVar:
+		return ast.Var{
+			Name:        d.ReadToken(),
+			Initializer: d.ReadExpression(),
+			Range:       d.ReadSpan(),
+		}
+	case tagWhile:
+		return ast.While{
+			Condition: d.ReadExpression(),
+			Body:      d.ReadStatement(),
+			Increment: d.ReadExpression(),
+			Range:     d.ReadSpan(),
+		}
+	case tagBreak:
+		return ast.Break{
+			Keyword: d.ReadToken(),
+			Range:   d.ReadSpan(),
+		}
+	case tagContinue:
+		return ast.Continue{
+			Keyword: d.ReadToken(),
+			Range:   d.ReadSpan(),
+		}
+	default:
+		d.Error(fmt.Sprintf("unknown node tag %d", tag))
+		return nil
+	}
+}
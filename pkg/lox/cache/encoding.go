@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/token"
+	"math"
+	"math/big"
+)
+
+// MAGIC starts every encoded tree. VERSION must be bumped whenever the AST,
+// the token types or this encoding change, so that old files are rebuilt
+// instead of being decoded into the wrong nodes.
+const (
+	MAGIC   = "GLXA"
+	VERSION = 2
+)
+
+const (
+	tagNil byte = iota
+	tagAssignment
+	tagBinary
+	tagCall
+	tagGet
+	tagGrouping
+	tagLiteral
+	tagLogical
+	tagSet
+	tagSuper
+	tagThis
+	tagUnary
+	tagVariable
+	tagBlock
+	tagClass
+	tagExpressionStatement
+	tagFunction
+	tagIf
+	tagPrint
+	tagReturn
+	tagVar
+	tagWhile
+	tagBreak
+	tagContinue
+)
+
+const (
+	valueNil byte = iota
+	valueFalse
+	valueTrue
+	valueInteger
+	valueFloat
+	valueBigInteger
+	valueString
+)
+
+// tokenTypes fingerprints the token enumeration, which is stored by number.
+const tokenTypes = uint64(token.EOF) + 1
+
+type DecodeError struct {
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	return "invalid AST encoding: " + e.Message
+}
+
+// Encode serializes statements into the versioned binary format read by
+// Decode. Integers are written as varints and strings are length-prefixed.
+func Encode(statements []ast.Statement) []byte {
+	e := &Encoder{}
+	e.Buffer = append(e.Buffer, MAGIC...)
+	e.WriteUint(VERSION)
+	e.WriteUint(tokenTypes)
+	e.WriteStatements(statements)
+	return e.Buffer
+}
+
+type Encoder struct {
+	Buffer []byte
+}
+
+func (e *Encoder) WriteUint(value uint64) {
+	e.Buffer = binary.AppendUvarint(e.Buffer, value)
+}
+
+func (e *Encoder) WriteInt(value int64) {
+	e.Buffer = binary.AppendVarint(e.Buffer, value)
+}
+
+func (e *Encoder) WriteString(value string) {
+	e.WriteUint(uint64(len(value)))
+	e.Buffer = append(e.Buffer, value...)
+}
+
+func (e *Encoder) WritePosition(p token.Position) {
+	e.WriteInt(int64(p.Line))
+	e.WriteInt(int64(p.Column))
+	e.WriteInt(int64(p.Offset))
+}
+
+func (e *Encoder) WriteSpan(s token.Span) {
+	e.WritePosition(s.Start)
+	e.WritePosition(s.End)
+}
+
+func (e *Encoder) WriteValue(value interface{}) {
+	switch value := value.(type) {
+	case nil:
+		e.Buffer = append(e.Buffer, valueNil)
+	case bool:
+		if value {
+			e.Buffer = append(e.Buffer, valueTrue)
+		} else {
+			e.Buffer = append(e.Buffer, valueFalse)
+		}
+	case int64:
+		e.Buffer = append(e.Buffer, valueInteger)
+		e.WriteInt(value)
+	case float64:
+		e.Buffer = append(e.Buffer, valueFloat)
+		e.Buffer = binary.LittleEndian.AppendUint64(e.Buffer, math.Float64bits(value))
+	case *big.Int:
+		e.Buffer = append(e.Buffer, valueBigInteger)
+		e.WriteString(value.String())
+	case string:
+		e.Buffer = append(e.Buffer, valueString)
+		e.WriteString(value)
+	default:
+		panic(fmt.Sprintf("cannot encode literal of type %T", value))
+	}
+}
+
+func (e *Encoder) WriteToken(t token.Token) {
+	e.WriteUint(uint64(t.Type))
+	e.WriteString(t.Lexeme)
+	e.WriteValue(t.Literal)
+	e.WriteInt(int64(t.Line))
+	e.WriteInt(int64(t.Column))
+	e.WriteInt(int64(t.Offset))
+}
+
+func (e *Encoder) WriteTokens(tokens []token.Token) {
+	e.WriteUint(uint64(len(tokens)))
+	for _, t := range tokens {
+		e.WriteToken(t)
+	}
+}
+
+func (e *Encoder) WriteNode(node interface {
+	Accept(v ast.Visitor) interface{}
+}) {
+	if node == nil {
+		e.Buffer = append(e.Buffer, tagNil)
+		return
+	}
+	node.Accept(e)
+}
+
+func (e *Encoder) WriteExpressions(expressions []ast.Expression) {
+	e.WriteUint(uint64(len(expressions)))
+	for _, expression := range expressions {
+		e.WriteNode(expression)
+	}
+}
+
+func (e *Encoder) WriteStatements(statements []ast.Statement) {
+	e.WriteUint(uint64(len(statements)))
+	for _, statement := range statements {
+		e.WriteNode(statement)
+	}
+}
+
+func (e *Encoder) WriteFunction(stmt *ast.Function) {
+	e.WriteToken(stmt.Name)
+	e.WriteTokens(stmt.Params)
+	e.WriteStatements(stmt.Body)
+	e.WriteSpan(stmt.Range)
+}
+
+func (e *Encoder) VisitAssignment(expr *ast.Assignment) interface{} {
+	e.Buffer = append(e.Buffer, tagAssignment)
+	e.WriteToken(expr.Name)
+	e.WriteNode(expr.Value)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitBinary(expr *ast.Binary) interface{} {
+	e.Buffer = append(e.Buffer, tagBinary)
+	e.WriteToken(expr.Operation)
+	e.WriteNode(expr.Left)
+	e.WriteNode(expr.Right)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitCall(expr *ast.Call) interface{} {
+	e.Buffer = append(e.Buffer, tagCall)
+	e.WriteNode(expr.Callee)
+	e.WriteToken(expr.Paren)
+	e.WriteExpressions(expr.Arguments)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitGet(expr *ast.Get) interface{} {
+	e.Buffer = append(e.Buffer, tagGet)
+	e.WriteNode(expr.Object)
+	e.WriteToken(expr.Name)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitGrouping(expr *ast.Grouping) interface{} {
+	e.Buffer = append(e.Buffer, tagGrouping)
+	e.WriteNode(expr.Expr)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitLiteral(expr *ast.Literal) interface{} {
+	e.Buffer = append(e.Buffer, tagLiteral)
+	e.WriteValue(expr.Value)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitLogical(expr *ast.Logical) interface{} {
+	e.Buffer = append(e.Buffer, tagLogical)
+	e.WriteToken(expr.Operation)
+	e.WriteNode(expr.Left)
+	e.WriteNode(expr.Right)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitSet(expr *ast.Set) interface{} {
+	e.Buffer = append(e.Buffer, tagSet)
+	e.WriteNode(expr.Object)
+	e.WriteToken(expr.Name)
+	e.WriteNode(expr.Value)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitSuper(expr *ast.Super) interface{} {
+	e.Buffer = append(e.Buffer, tagSuper)
+	e.WriteToken(expr.Keyword)
+	e.WriteToken(expr.Method)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitThis(expr *ast.This) interface{} {
+	e.Buffer = append(e.Buffer, tagThis)
+	e.WriteToken(expr.Keyword)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitUnary(expr *ast.Unary) interface{} {
+	e.Buffer = append(e.Buffer, tagUnary)
+	e.WriteToken(expr.Operation)
+	e.WriteNode(expr.Operand)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitVariable(expr *ast.Variable) interface{} {
+	e.Buffer = append(e.Buffer, tagVariable)
+	e.WriteToken(expr.Name)
+	e.WriteSpan(expr.Range)
+	return nil
+}
+
+func (e *Encoder) VisitBlock(stmt *ast.Block) interface{} {
+	e.Buffer = append(e.Buffer, tagBlock)
+	e.WriteStatements(stmt.Statements)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitBreak(stmt *ast.Break) interface{} {
+	e.Buffer = append(e.Buffer, tagBreak)
+	e.WriteToken(stmt.Keyword)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitClass(stmt *ast.Class) interface{} {
+	e.Buffer = append(e.Buffer, tagClass)
+	e.WriteToken(stmt.Name)
+	if stmt.Superclass != nil {
+		e.WriteNode(stmt.Superclass)
+	} else {
+		e.WriteNode(nil)
+	}
+	e.WriteUint(uint64(len(stmt.Methods)))
+	for index := range stmt.Methods {
+		e.WriteFunction(&stmt.Methods[index])
+	}
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitContinue(stmt *ast.Continue) interface{} {
+	e.Buffer = append(e.Buffer, tagContinue)
+	e.WriteToken(stmt.Keyword)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
+	e.Buffer = append(e.Buffer, tagExpressionStatement)
+	e.WriteNode(stmt.Expr)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitFunction(stmt *ast.Function) interface{} {
+	e.Buffer = append(e.Buffer, tagFunction)
+	e.WriteFunction(stmt)
+	return nil
+}
+
+func (e *Encoder) VisitIf(stmt *ast.If) interface{} {
+	e.Buffer = append(e.Buffer, tagIf)
+	e.WriteNode(stmt.Condition)
+	e.WriteNode(stmt.ThenBranch)
+	e.WriteNode(stmt.ElseBranch)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitPrint(stmt *ast.Print) interface{} {
+	e.Buffer = append(e.Buffer, tagPrint)
+	e.WriteNode(stmt.Expr)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitReturn(stmt *ast.Return) interface{} {
+	e.Buffer = append(e.Buffer, tagReturn)
+	e.WriteToken(stmt.Keyword)
+	e.WriteNode(stmt.Value)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitVar(stmt *ast.Var) interface{} {
+	e.Buffer = append(e.Buffer, tagVar)
+	e.WriteToken(stmt.Name)
+	e.WriteNode(stmt.Initializer)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
+
+func (e *Encoder) VisitWhile(stmt *ast.While) interface{} {
+	e.Buffer = append(e.Buffer, tagWhile)
+	e.WriteNode(stmt.Condition)
+	e.WriteNode(stmt.Body)
+	e.WriteNode(stmt.Increment)
+	e.WriteSpan(stmt.Range)
+	return nil
+}
@@ -0,0 +1,162 @@
+package compiler
+
+import (
+	"golox/pkg/lox/token"
+	"sort"
+)
+
+type OpCode byte
+
+const (
+	OP_CONSTANT OpCode = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_GLOBAL
+	OP_DEFINE_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+	OP_GET_PROPERTY
+	OP_SET_PROPERTY
+	OP_GET_SUPER
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_MODULO
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_CALL
+	OP_TAIL_CALL
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS
+	OP_INHERIT
+	OP_METHOD
+)
+
+var opNames = map[OpCode]string{
+	OP_CONSTANT:      "OP_CONSTANT",
+	OP_NIL:           "OP_NIL",
+	OP_TRUE:          "OP_TRUE",
+	OP_FALSE:         "OP_FALSE",
+	OP_POP:           "OP_POP",
+	OP_GET_LOCAL:     "OP_GET_LOCAL",
+	OP_SET_LOCAL:     "OP_SET_LOCAL",
+	OP_GET_GLOBAL:    "OP_GET_GLOBAL",
+	OP_DEFINE_GLOBAL: "OP_DEFINE_GLOBAL",
+	OP_SET_GLOBAL:    "OP_SET_GLOBAL",
+	OP_GET_UPVALUE:   "OP_GET_UPVALUE",
+	OP_SET_UPVALUE:   "OP_SET_UPVALUE",
+	OP_GET_PROPERTY:  "OP_GET_PROPERTY",
+	OP_SET_PROPERTY:  "OP_SET_PROPERTY",
+	OP_GET_SUPER:     "OP_GET_SUPER",
+	OP_EQUAL:         "OP_EQUAL",
+	OP_NOT_EQUAL:     "OP_NOT_EQUAL",
+	OP_GREATER:       "OP_GREATER",
+	OP_GREATER_EQUAL: "OP_GREATER_EQUAL",
+	OP_LESS:          "OP_LESS",
+	OP_LESS_EQUAL:    "OP_LESS_EQUAL",
+	OP_ADD:           "OP_ADD",
+	OP_SUBTRACT:      "OP_SUBTRACT",
+	OP_MULTIPLY:      "OP_MULTIPLY",
+	OP_DIVIDE:        "OP_DIVIDE",
+	OP_MODULO:        "OP_MODULO",
+	OP_NOT:           "OP_NOT",
+	OP_NEGATE:        "OP_NEGATE",
+	OP_PRINT:         "OP_PRINT",
+	OP_JUMP:          "OP_JUMP",
+	OP_JUMP_IF_FALSE: "OP_JUMP_IF_FALSE",
+	OP_LOOP:          "OP_LOOP",
+	OP_CALL:          "OP_CALL",
+	OP_TAIL_CALL:     "OP_TAIL_CALL",
+	OP_CLOSURE:       "OP_CLOSURE",
+	OP_CLOSE_UPVALUE: "OP_CLOSE_UPVALUE",
+	OP_RETURN:        "OP_RETURN",
+	OP_CLASS:         "OP_CLASS",
+	OP_INHERIT:       "OP_INHERIT",
+	OP_METHOD:        "OP_METHOD",
+}
+
+func (op OpCode) String() string {
+	name, ok := opNames[op]
+	if ok {
+		return name
+	}
+	return "OP_UNKNOWN"
+}
+
+// Line records the source token for every instruction from Offset up to the
+// next entry in the table.
+type Line struct {
+	Offset int
+	Token  token.Token
+}
+
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	Lines     []Line
+}
+
+func NewChunk() *Chunk {
+	return &Chunk{
+		Code:      make([]byte, 0),
+		Constants: make([]interface{}, 0),
+		Lines:     make([]Line, 0),
+	}
+}
+
+func (c *Chunk) Write(b byte, t token.Token) {
+	if len(c.Lines) == 0 || c.Lines[len(c.Lines)-1].Token != t {
+		c.Lines = append(c.Lines, Line{
+			Offset: len(c.Code),
+			Token:  t,
+		})
+	}
+	c.Code = append(c.Code, b)
+}
+
+func (c *Chunk) AddConstant(value interface{}) int {
+	for i, constant := range c.Constants {
+		if isSameConstant(constant, value) {
+			return i
+		}
+	}
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+func (c *Chunk) TokenAt(offset int) token.Token {
+	i := sort.Search(len(c.Lines), func(i int) bool {
+		return c.Lines[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Token{}
+	}
+	return c.Lines[i-1].Token
+}
+
+func isSameConstant(a interface{}, b interface{}) bool {
+	switch a.(type) {
+	case int64, float64, string:
+		return a == b
+	default:
+		return false
+	}
+}
@@ -0,0 +1,728 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/token"
+	"math"
+)
+
+// Compile lowers a resolved program to bytecode. The returned Function is the
+// top-level script; it takes no arguments and has no name.
+func Compile(statements []ast.Statement) (*Function, error) {
+	compiler := NewCompiler(nil, SCRIPT, "")
+	compiler.CompileStatements(statements)
+	function := compiler.End(token.Token{})
+	return function, errors.Join(compiler.Errors...)
+}
+
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        *Chunk
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return "<fn " + f.Name + ">"
+}
+
+type FunctionType int
+
+const (
+	SCRIPT FunctionType = iota
+	FUNCTION
+	INITIALIZER
+	METHOD
+)
+
+type CompileError struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("[line %d:%d] %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+func (e *CompileError) Span() token.Span {
+	return e.Token.Span()
+}
+
+func (e *CompileError) Reason() string {
+	return e.Message
+}
+
+type Local struct {
+	Name       string
+	Depth      int
+	IsCaptured bool
+}
+
+type Upvalue struct {
+	Index   byte
+	IsLocal bool
+}
+
+type ClassCompiler struct {
+	Enclosing     *ClassCompiler
+	HasSuperclass bool
+}
+
+// Loop records the jumps out of the innermost loop being compiled, which are
+// patched once the loop's end and increment are known.
+type Loop struct {
+	Enclosing  *Loop
+	ScopeDepth int
+	Breaks     []int
+	Continues  []int
+}
+
+type Compiler struct {
+	Enclosing  *Compiler
+	Function   *Function
+	Type       FunctionType
+	Locals     []Local
+	Upvalues   []Upvalue
+	ScopeDepth int
+	Class      *ClassCompiler
+	Loop       *Loop
+	Errors     []error
+}
+
+func NewCompiler(enclosing *Compiler, typ FunctionType, name string) *Compiler {
+	c := &Compiler{
+		Enclosing: enclosing,
+		Function: &Function{
+			Name:  name,
+			Chunk: NewChunk(),
+		},
+		Type:     typ,
+		Locals:   make([]Local, 0),
+		Upvalues: make([]Upvalue, 0),
+		Errors:   make([]error, 0),
+	}
+
+	if enclosing != nil {
+		c.Class = enclosing.Class
+	}
+
+	slot := ""
+	if typ == METHOD || typ == INITIALIZER {
+		slot = "this"
+	}
+	c.Locals = append(c.Locals, Local{Name: slot, Depth: 0})
+
+	return c
+}
+
+func (c *Compiler) End(t token.Token) *Function {
+	c.EmitReturn(t)
+	c.Function.UpvalueCount = len(c.Upvalues)
+	if c.Enclosing != nil {
+		c.Enclosing.Errors = append(c.Enclosing.Errors, c.Errors...)
+	}
+	return c.Function
+}
+
+func (c *Compiler) Error(t token.Token, message string) {
+	c.Errors = append(c.Errors, &CompileError{
+		Token:   t,
+		Message: message,
+	})
+}
+
+func (c *Compiler) CompileStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		statement.Accept(c)
+	}
+}
+
+func (c *Compiler) Emit(t token.Token, bytes ...byte) {
+	for _, b := range bytes {
+		c.Function.Chunk.Write(b, t)
+	}
+}
+
+func (c *Compiler) EmitOp(t token.Token, op OpCode, operands ...byte) {
+	c.Emit(t, byte(op))
+	c.Emit(t, operands...)
+}
+
+func (c *Compiler) EmitShort(t token.Token, op OpCode, operand int) {
+	c.Emit(t, byte(op), byte(operand>>8), byte(operand))
+}
+
+func (c *Compiler) EmitReturn(t token.Token) {
+	if c.Type == INITIALIZER {
+		c.EmitOp(t, OP_GET_LOCAL, 0)
+	} else {
+		c.EmitOp(t, OP_NIL)
+	}
+	c.EmitOp(t, OP_RETURN)
+}
+
+func (c *Compiler) MakeConstant(t token.Token, value interface{}) int {
+	index := c.Function.Chunk.AddConstant(value)
+	if index > math.MaxUint16 {
+		c.Error(t, "too many constants in one chunk")
+		return 0
+	}
+	return index
+}
+
+func (c *Compiler) EmitConstant(t token.Token, value interface{}) {
+	c.EmitShort(t, OP_CONSTANT, c.MakeConstant(t, value))
+}
+
+func (c *Compiler) EmitJump(t token.Token, op OpCode) int {
+	c.EmitShort(t, op, 0xffff)
+	return len(c.Function.Chunk.Code) - 2
+}
+
+func (c *Compiler) PatchJump(t token.Token, offset int) {
+	jump := len(c.Function.Chunk.Code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.Error(t, "too much code to jump over")
+	}
+	c.Function.Chunk.Code[offset] = byte(jump >> 8)
+	c.Function.Chunk.Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) EmitLoop(t token.Token, start int) {
+	offset := len(c.Function.Chunk.Code) - start + 3
+	if offset > math.MaxUint16 {
+		c.Error(t, "loop body too large")
+	}
+	c.EmitShort(t, OP_LOOP, offset)
+}
+
+func (c *Compiler) BeginScope() {
+	c.ScopeDepth++
+}
+
+func (c *Compiler) EndScope(t token.Token) {
+	c.ScopeDepth--
+
+	for len(c.Locals) > 0 && c.Locals[len(c.Locals)-1].Depth > c.ScopeDepth {
+		if c.Locals[len(c.Locals)-1].IsCaptured {
+			c.EmitOp(t, OP_CLOSE_UPVALUE)
+		} else {
+			c.EmitOp(t, OP_POP)
+		}
+		c.Locals = c.Locals[:len(c.Locals)-1]
+	}
+}
+
+// DiscardLocals pops the locals declared inside the innermost loop before a
+// break or continue jumps out of their scope. The locals stay declared, since
+// the code after the jump is still inside that scope. Each slot is closed
+// rather than popped because a closure declared later in the loop body may
+// still capture it.
+func (c *Compiler) DiscardLocals(t token.Token) {
+	for i := len(c.Locals) - 1; i >= 0 && c.Locals[i].Depth > c.Loop.ScopeDepth; i-- {
+		c.EmitOp(t, OP_CLOSE_UPVALUE)
+	}
+}
+
+func (c *Compiler) AddLocal(name token.Token) {
+	if len(c.Locals) > math.MaxUint8 {
+		c.Error(name, "too many local variables in function")
+		return
+	}
+	c.Locals = append(c.Locals, Local{
+		Name:  name.Lexeme,
+		Depth: -1,
+	})
+}
+
+func (c *Compiler) MarkInitialized() {
+	if c.ScopeDepth == 0 {
+		return
+	}
+	c.Locals[len(c.Locals)-1].Depth = c.ScopeDepth
+}
+
+// DeclareVariable adds a local for name when inside a scope and returns the
+// constant holding its name when declaring a global.
+func (c *Compiler) DeclareVariable(name token.Token) int {
+	if c.ScopeDepth > 0 {
+		c.AddLocal(name)
+		return 0
+	}
+	return c.MakeConstant(name, name.Lexeme)
+}
+
+func (c *Compiler) DefineVariable(name token.Token, global int) {
+	if c.ScopeDepth > 0 {
+		c.MarkInitialized()
+		return
+	}
+	c.EmitShort(name, OP_DEFINE_GLOBAL, global)
+}
+
+func (c *Compiler) ResolveLocal(name string) int {
+	for i := len(c.Locals) - 1; i >= 0; i-- {
+		if c.Locals[i].Name == name && c.Locals[i].Depth != -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) AddUpvalue(t token.Token, index byte, isLocal bool) int {
+	for i, upvalue := range c.Upvalues {
+		if upvalue.Index == index && upvalue.IsLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(c.Upvalues) > math.MaxUint8 {
+		c.Error(t, "too many closure variables in function")
+		return 0
+	}
+
+	c.Upvalues = append(c.Upvalues, Upvalue{
+		Index:   index,
+		IsLocal: isLocal,
+	})
+	return len(c.Upvalues) - 1
+}
+
+func (c *Compiler) ResolveUpvalue(t token.Token, name string) int {
+	if c.Enclosing == nil {
+		return -1
+	}
+
+	local := c.Enclosing.ResolveLocal(name)
+	if local != -1 {
+		c.Enclosing.Locals[local].IsCaptured = true
+		return c.AddUpvalue(t, byte(local), true)
+	}
+
+	upvalue := c.Enclosing.ResolveUpvalue(t, name)
+	if upvalue != -1 {
+		return c.AddUpvalue(t, byte(upvalue), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) NamedVariable(name token.Token, assign ast.Expression) {
+	var get, set OpCode
+	var arg int
+
+	if arg = c.ResolveLocal(name.Lexeme); arg != -1 {
+		get, set = OP_GET_LOCAL, OP_SET_LOCAL
+	} else if arg = c.ResolveUpvalue(name, name.Lexeme); arg != -1 {
+		get, set = OP_GET_UPVALUE, OP_SET_UPVALUE
+	} else {
+		arg = c.MakeConstant(name, name.Lexeme)
+		if assign != nil {
+			assign.Accept(c)
+			c.EmitShort(name, OP_SET_GLOBAL, arg)
+		} else {
+			c.EmitShort(name, OP_GET_GLOBAL, arg)
+		}
+		return
+	}
+
+	if assign != nil {
+		assign.Accept(c)
+		c.EmitOp(name, set, byte(arg))
+	} else {
+		c.EmitOp(name, get, byte(arg))
+	}
+}
+
+func (c *Compiler) CompileFunction(stmt *ast.Function, typ FunctionType) {
+	compiler := NewCompiler(c, typ, stmt.Name.Lexeme)
+	compiler.BeginScope()
+
+	compiler.Function.Arity = len(stmt.Params)
+	if compiler.Function.Arity > math.MaxUint8 {
+		compiler.Error(stmt.Params[math.MaxUint8], "can't have more than 255 parameters")
+	}
+
+	for _, param := range stmt.Params {
+		compiler.AddLocal(param)
+		compiler.MarkInitialized()
+	}
+
+	compiler.CompileStatements(stmt.Body)
+
+	end := TokenAt(stmt.Range.End)
+	function := compiler.End(end)
+
+	c.EmitShort(stmt.Name, OP_CLOSURE, c.MakeConstant(stmt.Name, function))
+	for _, upvalue := range compiler.Upvalues {
+		isLocal := byte(0)
+		if upvalue.IsLocal {
+			isLocal = 1
+		}
+		c.Emit(stmt.Name, isLocal, upvalue.Index)
+	}
+}
+
+// TokenAt builds a placeholder token for nodes that have a position but no
+// token of their own, such as literals.
+func TokenAt(position token.Position) token.Token {
+	return token.Token{
+		Line:   position.Line,
+		Column: position.Column,
+		Offset: position.Offset,
+	}
+}
+
+func (c *Compiler) VisitAssignment(expr *ast.Assignment) interface{} {
+	c.NamedVariable(expr.Name, expr.Value)
+	return nil
+}
+
+func (c *Compiler) VisitBinary(expr *ast.Binary) interface{} {
+	expr.Left.Accept(c)
+	expr.Right.Accept(c)
+
+	operator := expr.Operation
+	switch operator.Type {
+	case token.EQUAL_EQUAL:
+		c.EmitOp(operator, OP_EQUAL)
+	case token.BANG_EQUAL:
+		c.EmitOp(operator, OP_NOT_EQUAL)
+	case token.GREATER:
+		c.EmitOp(operator, OP_GREATER)
+	case token.GREATER_EQUAL:
+		c.EmitOp(operator, OP_GREATER_EQUAL)
+	case token.LESS:
+		c.EmitOp(operator, OP_LESS)
+	case token.LESS_EQUAL:
+		c.EmitOp(operator, OP_LESS_EQUAL)
+	case token.PLUS:
+		c.EmitOp(operator, OP_ADD)
+	case token.MINUS:
+		c.EmitOp(operator, OP_SUBTRACT)
+	case token.STAR:
+		c.EmitOp(operator, OP_MULTIPLY)
+	case token.SLASH:
+		c.EmitOp(operator, OP_DIVIDE)
+	case token.PERCENT:
+		c.EmitOp(operator, OP_MODULO)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitCall(expr *ast.Call) interface{} {
+	c.CompileCall(expr, OP_CALL)
+	return nil
+}
+
+// CompileCall pushes the callee and arguments, then calls with op.
+func (c *Compiler) CompileCall(expr *ast.Call, op OpCode) {
+	expr.Callee.Accept(c)
+	for _, argument := range expr.Arguments {
+		argument.Accept(c)
+	}
+
+	if len(expr.Arguments) > math.MaxUint8 {
+		c.Error(expr.Paren, "can't have more than 255 arguments")
+	}
+
+	c.EmitOp(expr.Paren, op, byte(len(expr.Arguments)))
+}
+
+func (c *Compiler) VisitGet(expr *ast.Get) interface{} {
+	expr.Object.Accept(c)
+	c.EmitShort(expr.Name, OP_GET_PROPERTY, c.MakeConstant(expr.Name, expr.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitGrouping(expr *ast.Grouping) interface{} {
+	expr.Expr.Accept(c)
+	return nil
+}
+
+func (c *Compiler) VisitLiteral(expr *ast.Literal) interface{} {
+	t := TokenAt(expr.Range.Start)
+	switch expr.Value.(type) {
+	case nil:
+		c.EmitOp(t, OP_NIL)
+	case bool:
+		if expr.Value.(bool) {
+			c.EmitOp(t, OP_TRUE)
+		} else {
+			c.EmitOp(t, OP_FALSE)
+		}
+	default:
+		c.EmitConstant(t, expr.Value)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLogical(expr *ast.Logical) interface{} {
+	expr.Left.Accept(c)
+
+	operator := expr.Operation
+	if operator.Type == token.AND {
+		end := c.EmitJump(operator, OP_JUMP_IF_FALSE)
+		c.EmitOp(operator, OP_POP)
+		expr.Right.Accept(c)
+		c.PatchJump(operator, end)
+		return nil
+	}
+
+	elseJump := c.EmitJump(operator, OP_JUMP_IF_FALSE)
+	end := c.EmitJump(operator, OP_JUMP)
+	c.PatchJump(operator, elseJump)
+	c.EmitOp(operator, OP_POP)
+	expr.Right.Accept(c)
+	c.PatchJump(operator, end)
+	return nil
+}
+
+func (c *Compiler) VisitSet(expr *ast.Set) interface{} {
+	expr.Object.Accept(c)
+	expr.Value.Accept(c)
+	c.EmitShort(expr.Name, OP_SET_PROPERTY, c.MakeConstant(expr.Name, expr.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitSuper(expr *ast.Super) interface{} {
+	if c.Class == nil || !c.Class.HasSuperclass {
+		c.Error(expr.Keyword, "can't use 'super' in a class with no superclass")
+		return nil
+	}
+
+	name := c.MakeConstant(expr.Method, expr.Method.Lexeme)
+
+	c.NamedVariable(token.Token{Type: token.THIS, Lexeme: "this", Line: expr.Keyword.Line, Column: expr.Keyword.Column, Offset: expr.Keyword.Offset}, nil)
+	c.NamedVariable(expr.Keyword, nil)
+	c.EmitShort(expr.Method, OP_GET_SUPER, name)
+	return nil
+}
+
+func (c *Compiler) VisitThis(expr *ast.This) interface{} {
+	if c.Class == nil {
+		c.Error(expr.Keyword, "can't use 'this' outside of a class")
+		return nil
+	}
+
+	c.NamedVariable(expr.Keyword, nil)
+	return nil
+}
+
+func (c *Compiler) VisitUnary(expr *ast.Unary) interface{} {
+	expr.Operand.Accept(c)
+
+	switch expr.Operation.Type {
+	case token.BANG:
+		c.EmitOp(expr.Operation, OP_NOT)
+	case token.MINUS:
+		c.EmitOp(expr.Operation, OP_NEGATE)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitVariable(expr *ast.Variable) interface{} {
+	c.NamedVariable(expr.Name, nil)
+	return nil
+}
+
+func (c *Compiler) VisitBlock(stmt *ast.Block) interface{} {
+	c.BeginScope()
+	c.CompileStatements(stmt.Statements)
+	c.EndScope(TokenAt(stmt.Range.End))
+	return nil
+}
+
+func (c *Compiler) VisitBreak(stmt *ast.Break) interface{} {
+	if c.Loop == nil {
+		c.Error(stmt.Keyword, "can't use 'break' outside of a loop")
+		return nil
+	}
+
+	c.DiscardLocals(stmt.Keyword)
+	c.Loop.Breaks = append(c.Loop.Breaks, c.EmitJump(stmt.Keyword, OP_JUMP))
+	return nil
+}
+
+func (c *Compiler) VisitClass(stmt *ast.Class) interface{} {
+	name := stmt.Name
+	constant := c.MakeConstant(name, name.Lexeme)
+	global := c.DeclareVariable(name)
+
+	c.EmitShort(name, OP_CLASS, constant)
+	c.DefineVariable(name, global)
+
+	class := &ClassCompiler{
+		Enclosing: c.Class,
+	}
+	c.Class = class
+
+	if stmt.Superclass != nil {
+		superclass := stmt.Superclass.Name
+		if superclass.Lexeme == name.Lexeme {
+			c.Error(superclass, "a class can't inherit from itself")
+		}
+
+		c.NamedVariable(superclass, nil)
+
+		c.BeginScope()
+		c.AddLocal(token.Token{Type: token.SUPER, Lexeme: "super", Line: superclass.Line, Column: superclass.Column, Offset: superclass.Offset})
+		c.MarkInitialized()
+
+		c.NamedVariable(name, nil)
+		c.EmitOp(superclass, OP_INHERIT)
+		class.HasSuperclass = true
+	}
+
+	c.NamedVariable(name, nil)
+	for index := range stmt.Methods {
+		method := &stmt.Methods[index]
+		typ := METHOD
+		if method.Name.Lexeme == "init" {
+			typ = INITIALIZER
+		}
+		c.CompileFunction(method, typ)
+		c.EmitShort(method.Name, OP_METHOD, c.MakeConstant(method.Name, method.Name.Lexeme))
+	}
+
+	end := TokenAt(stmt.Range.End)
+	c.EmitOp(end, OP_POP)
+
+	if class.HasSuperclass {
+		c.EndScope(end)
+	}
+
+	c.Class = class.Enclosing
+	return nil
+}
+
+func (c *Compiler) VisitContinue(stmt *ast.Continue) interface{} {
+	if c.Loop == nil {
+		c.Error(stmt.Keyword, "can't use 'continue' outside of a loop")
+		return nil
+	}
+
+	c.DiscardLocals(stmt.Keyword)
+	c.Loop.Continues = append(c.Loop.Continues, c.EmitJump(stmt.Keyword, OP_JUMP))
+	return nil
+}
+
+func (c *Compiler) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
+	stmt.Expr.Accept(c)
+	c.EmitOp(TokenAt(stmt.Range.End), OP_POP)
+	return nil
+}
+
+func (c *Compiler) VisitFunction(stmt *ast.Function) interface{} {
+	global := c.DeclareVariable(stmt.Name)
+	c.MarkInitialized()
+	c.CompileFunction(stmt, FUNCTION)
+	c.DefineVariable(stmt.Name, global)
+	return nil
+}
+
+func (c *Compiler) VisitIf(stmt *ast.If) interface{} {
+	t := TokenAt(stmt.Range.Start)
+
+	stmt.Condition.Accept(c)
+
+	thenJump := c.EmitJump(t, OP_JUMP_IF_FALSE)
+	c.EmitOp(t, OP_POP)
+	stmt.ThenBranch.Accept(c)
+
+	elseJump := c.EmitJump(t, OP_JUMP)
+	c.PatchJump(t, thenJump)
+	c.EmitOp(t, OP_POP)
+
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(c)
+	}
+	c.PatchJump(t, elseJump)
+	return nil
+}
+
+func (c *Compiler) VisitPrint(stmt *ast.Print) interface{} {
+	stmt.Expr.Accept(c)
+	c.EmitOp(TokenAt(stmt.Range.Start), OP_PRINT)
+	return nil
+}
+
+func (c *Compiler) VisitReturn(stmt *ast.Return) interface{} {
+	if c.Type == SCRIPT {
+		c.Error(stmt.Keyword, "can't return from top-level code")
+	}
+
+	if stmt.Value == nil {
+		c.EmitReturn(stmt.Keyword)
+		return nil
+	}
+
+	if c.Type == INITIALIZER {
+		c.Error(stmt.Keyword, "can't return a value from an initializer")
+	}
+
+	// A call in tail position may replace this function's frame; the return
+	// after it is only reached when the callee isn't a Lox function.
+	if call, ok := interpreter.TailCallOf(stmt.Value); ok {
+		c.CompileCall(call, OP_TAIL_CALL)
+	} else {
+		stmt.Value.Accept(c)
+	}
+	c.EmitOp(stmt.Keyword, OP_RETURN)
+	return nil
+}
+
+func (c *Compiler) VisitVar(stmt *ast.Var) interface{} {
+	global := c.DeclareVariable(stmt.Name)
+
+	if stmt.Initializer != nil {
+		stmt.Initializer.Accept(c)
+	} else {
+		c.EmitOp(stmt.Name, OP_NIL)
+	}
+
+	c.DefineVariable(stmt.Name, global)
+	return nil
+}
+
+func (c *Compiler) VisitWhile(stmt *ast.While) interface{} {
+	t := TokenAt(stmt.Range.Start)
+
+	start := len(c.Function.Chunk.Code)
+	stmt.Condition.Accept(c)
+
+	exit := c.EmitJump(t, OP_JUMP_IF_FALSE)
+	c.EmitOp(t, OP_POP)
+
+	loop := &Loop{
+		Enclosing:  c.Loop,
+		ScopeDepth: c.ScopeDepth,
+		Breaks:     make([]int, 0),
+		Continues:  make([]int, 0),
+	}
+	c.Loop = loop
+	stmt.Body.Accept(c)
+	c.Loop = loop.Enclosing
+
+	for _, jump := range loop.Continues {
+		c.PatchJump(t, jump)
+	}
+	if stmt.Increment != nil {
+		stmt.Increment.Accept(c)
+		c.EmitOp(t, OP_POP)
+	}
+	c.EmitLoop(t, start)
+
+	c.PatchJump(t, exit)
+	c.EmitOp(t, OP_POP)
+	for _, jump := range loop.Breaks {
+		c.PatchJump(t, jump)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package conformance runs Lox programs on every execution backend so that
+// their behaviour can be checked against each other.
+package conformance
+
+import (
+	"golox/pkg/lox/compiler"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/optimizer"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/vm"
+	"io"
+)
+
+var Backends = []string{"tree", "vm"}
+
+// Run scans, parses and resolves source, optionally optimizes it, then
+// executes it on the named backend, writing program output to w.
+func Run(backend string, optimize bool, source string, w io.Writer) error {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		return err
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		return err
+	}
+
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		return err
+	}
+
+	if optimize {
+		statements, _ = optimizer.Optimize(statements)
+	}
+
+	if backend == "vm" {
+		function, err := compiler.Compile(statements)
+		if err != nil {
+			return err
+		}
+		machine := vm.NewVM()
+		machine.Writer = w
+		return machine.Interpret(function)
+	}
+
+	i := interpreter.NewInterpreter()
+	i.Writer = w
+	i.Resolve(locals)
+	return i.Interpret(statements)
+}
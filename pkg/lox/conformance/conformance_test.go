@@ -0,0 +1,149 @@
+package conformance
+
+import (
+	"bytes"
+	"errors"
+	"golox/pkg/lox/compiler"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/token"
+	"golox/pkg/lox/vm"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	expectOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectRuntimeError = regexp.MustCompile(`// expect runtime error: (.*)`)
+)
+
+type expectation struct {
+	Output string
+	Error  string
+}
+
+func parseExpectations(source string) expectation {
+	var e expectation
+	for _, line := range strings.Split(source, "\n") {
+		if match := expectOutput.FindStringSubmatch(line); match != nil {
+			e.Output += match[1] + "\n"
+		}
+		if match := expectRuntimeError.FindStringSubmatch(line); match != nil {
+			e.Error = match[1]
+		}
+	}
+	return e
+}
+
+func TestConformance(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no conformance tests found")
+	}
+
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".lox"), func(t *testing.T) {
+			bytes, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source := string(bytes)
+			expected := parseExpectations(source)
+
+			for _, optimize := range []bool{false, true} {
+				outputs := make(map[string]string)
+				for _, backend := range Backends {
+					name := backend
+					if optimize {
+						name += " (optimized)"
+					}
+
+					output, message := run(backend, optimize, source)
+					if output != expected.Output {
+						t.Errorf("%s: expected output %q but got %q", name, expected.Output, output)
+					}
+					if message != expected.Error {
+						t.Errorf("%s: expected error %q but got %q", name, expected.Error, message)
+					}
+					outputs[backend] = output + message
+				}
+
+				for _, backend := range Backends[1:] {
+					if outputs[backend] != outputs[Backends[0]] {
+						t.Errorf("%s and %s disagree: %q vs %q", Backends[0], backend, outputs[Backends[0]], outputs[backend])
+					}
+				}
+			}
+		})
+	}
+}
+
+func run(backend string, optimize bool, source string) (string, string) {
+	var out bytes.Buffer
+	err := Run(backend, optimize, source, &out)
+	if err != nil {
+		return out.String(), err.Error()
+	}
+	return out.String(), ""
+}
+
+// A native that fails should stop either backend with its error at the call,
+// rather than bringing the host down.
+func TestNativeError(t *testing.T) {
+	fail := func(arguments []interface{}) (interface{}, error) {
+		return nil, errors.New("no such file")
+	}
+	source := "print \"before\";\nprint open(\"missing\");\nprint \"after\";\n"
+
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var treeOut bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.Writer = &treeOut
+	i.DefineNative("open", 1, fail)
+	i.Resolve(locals)
+	treeErr := i.Interpret(statements)
+
+	function, err := compiler.Compile(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vmOut bytes.Buffer
+	machine := vm.NewVM()
+	machine.Writer = &vmOut
+	machine.DefineNative("open", 1, fail)
+	vmErr := machine.Interpret(function)
+
+	for backend, err := range map[string]error{"tree": treeErr, "vm": vmErr} {
+		var runtimeError *interpreter.RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("%s: expected a 'RuntimeError', got %v", backend, err)
+		}
+		if runtimeError.Message != "no such file" || runtimeError.Line != 2 || runtimeError.Token.Type != token.RIGHT_PAREN {
+			t.Errorf("%s: unexpected error %v", backend, runtimeError)
+		}
+	}
+	if treeOut.String() != "before\n" || vmOut.String() != "before\n" {
+		t.Errorf("expected only the first line to print, got %q and %q", treeOut.String(), vmOut.String())
+	}
+}
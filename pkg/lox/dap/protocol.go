@@ -0,0 +1,146 @@
+// Package dap serves the Debug Adapter Protocol, which lets editors such as
+// VS Code and Neovim drive the debugger. Messages are JSON, each preceded by
+// a Content-Length header, as described at
+// https://microsoft.github.io/debug-adapter-protocol/specification.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+type ProtocolMessage struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+}
+
+type Request struct {
+	ProtocolMessage
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type Response struct {
+	ProtocolMessage
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type Event struct {
+	ProtocolMessage
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// ReadMessage reads the JSON body of one message: headers ending in a blank
+// line, then Content-Length bytes.
+func ReadMessage(reader *bufio.Reader) ([]byte, error) {
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading headers: %w", err)
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length '%s'", headers.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	return body, nil
+}
+
+func WriteMessage(writer io.Writer, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+type Capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsEvaluateForHovers        bool `json:"supportsEvaluateForHovers"`
+	SupportsTerminateRequest         bool `json:"supportsTerminateRequest"`
+}
+
+type LaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	NoDebug     bool   `json:"noDebug"`
+}
+
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type SourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type Breakpoint struct {
+	Verified bool    `json:"verified"`
+	Line     int     `json:"line"`
+	Source   *Source `json:"source,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type StackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *Source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+type FrameArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    int    `json:"frameId"`
+}
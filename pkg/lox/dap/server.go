@@ -0,0 +1,598 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/debugger"
+	"golox/pkg/lox/diagnostics"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// THREAD_ID identifies the only thread a script runs on.
+const THREAD_ID = 1
+
+// Server debugs one script per session. Requests are handled on the goroutine
+// calling Serve while the script runs on another, which waits in Stopped for
+// a request to resume it whenever it stops.
+type Server struct {
+	Reader *bufio.Reader
+	Writer io.Writer
+
+	Program    string
+	Text       string
+	Statements []ast.Statement
+	Debugger   *debugger.Debugger
+
+	// Resume passes the action that ends a stop to the script's goroutine,
+	// and Done is closed once the script has finished.
+	Resume chan debugger.Action
+	Done   chan struct{}
+
+	mu          sync.Mutex
+	seq         int
+	started     bool
+	stopped     bool
+	terminating bool
+	handles     []interface{}
+}
+
+func NewServer(reader io.Reader, writer io.Writer) *Server {
+	return &Server{
+		Reader:  bufio.NewReader(reader),
+		Writer:  writer,
+		Resume:  make(chan debugger.Action, 1),
+		Done:    make(chan struct{}),
+		handles: make([]interface{}, 0),
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the
+// connection.
+func (s *Server) Serve() error {
+	for {
+		body, err := ReadMessage(s.Reader)
+		if err == io.EOF {
+			s.Shutdown()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		request := &Request{}
+		err = json.Unmarshal(body, request)
+		if err != nil {
+			return fmt.Errorf("decoding request: %w", err)
+		}
+
+		if s.Handle(request) {
+			return nil
+		}
+	}
+}
+
+func (s *Server) Send(message interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch message := message.(type) {
+	case *Response:
+		message.Seq = s.seq
+	case *Event:
+		message.Seq = s.seq
+	}
+	WriteMessage(s.Writer, message)
+}
+
+func (s *Server) Respond(request *Request, body interface{}, err error) {
+	response := &Response{
+		ProtocolMessage: ProtocolMessage{Type: "response"},
+		RequestSeq:      request.Seq,
+		Success:         err == nil,
+		Command:         request.Command,
+		Body:            body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+		response.Body = nil
+	}
+	s.Send(response)
+}
+
+func (s *Server) SendEvent(event string, body interface{}) {
+	s.Send(&Event{
+		ProtocolMessage: ProtocolMessage{Type: "event"},
+		Event:           event,
+		Body:            body,
+	})
+}
+
+// Handle answers a request, reporting true once the session is over.
+// Requests that resume the program answer for themselves, since the answer
+// has to go out before the program sends anything more.
+func (s *Server) Handle(request *Request) bool {
+	var body interface{}
+	var err error
+
+	switch request.Command {
+	case "initialize":
+		s.Respond(request, Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil)
+		s.SendEvent("initialized", nil)
+		return false
+	case "launch":
+		err = s.Launch(request.Arguments)
+	case "setBreakpoints":
+		body, err = s.SetBreakpoints(request.Arguments)
+	case "setExceptionBreakpoints":
+		body = map[string]interface{}{"breakpoints": []Breakpoint{}}
+	case "configurationDone":
+		err = s.Start()
+	case "threads":
+		body = map[string]interface{}{
+			"threads": []Thread{{ID: THREAD_ID, Name: "main"}},
+		}
+	case "stackTrace":
+		body, err = s.StackTrace()
+	case "scopes":
+		body, err = s.Scopes(request.Arguments)
+	case "variables":
+		body, err = s.Variables(request.Arguments)
+	case "evaluate":
+		body, err = s.Evaluate(request.Arguments)
+	case "continue":
+		s.ResumeWith(request, debugger.CONTINUE, map[string]interface{}{"allThreadsContinued": true})
+		return false
+	case "next":
+		s.ResumeWith(request, debugger.STEP_OVER, nil)
+		return false
+	case "stepIn":
+		s.ResumeWith(request, debugger.STEP_INTO, nil)
+		return false
+	case "stepOut":
+		s.ResumeWith(request, debugger.STEP_OUT, nil)
+		return false
+	case "pause":
+		if s.Debugger == nil {
+			err = errors.New("no program has been launched")
+		} else {
+			s.Debugger.Pause()
+		}
+	case "terminate":
+		s.Shutdown()
+	case "disconnect":
+		s.Shutdown()
+		s.Respond(request, nil, nil)
+		return true
+	default:
+		err = fmt.Errorf("unsupported request '%s'", request.Command)
+	}
+
+	s.Respond(request, body, err)
+	return false
+}
+
+// Launch loads, parses and resolves the program. It starts running once the
+// client has sent its configuration, breakpoints included.
+func (s *Server) Launch(arguments json.RawMessage) error {
+	var launch LaunchArguments
+	err := json.Unmarshal(arguments, &launch)
+	if err != nil {
+		return err
+	}
+	if s.Debugger != nil {
+		return errors.New("a program has already been launched")
+	}
+
+	path, err := filepath.Abs(launch.Program)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.Program = path
+	s.Text = string(data)
+
+	statements, locals, err := Parse(s.Text)
+	if err != nil {
+		return errors.New(s.Describe(err))
+	}
+
+	i := interpreter.NewInterpreter()
+	i.Writer = &Output{Server: s, Category: "stdout"}
+	i.Resolve(locals)
+
+	s.Statements = statements
+	s.Debugger = debugger.NewDebugger(i, s)
+	s.Debugger.StopOnEntry = launch.StopOnEntry && !launch.NoDebug
+	return nil
+}
+
+func Parse(source string) ([]ast.Statement, map[ast.Expression]int, error) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locals, err := resolver.Resolve(statements)
+	return statements, locals, err
+}
+
+// Describe formats an error in the program the way golox would print it,
+// without colour.
+func (s *Server) Describe(err error) string {
+	var message bytes.Buffer
+	diagnostics.NewPrinter(&message).Print(filepath.Base(s.Program), s.Text, err)
+	return message.String()
+}
+
+// SetBreakpoints replaces the breakpoints in the program. Every line is
+// accepted: a line with no statement on it is never stopped at.
+func (s *Server) SetBreakpoints(arguments json.RawMessage) (interface{}, error) {
+	var request SetBreakpointsArguments
+	err := json.Unmarshal(arguments, &request)
+	if err != nil {
+		return nil, err
+	}
+	if s.Debugger == nil {
+		return nil, errors.New("no program has been launched")
+	}
+
+	path, err := filepath.Abs(request.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	breakpoints := make([]Breakpoint, 0, len(request.Breakpoints))
+	if path != s.Program {
+		for _, breakpoint := range request.Breakpoints {
+			breakpoints = append(breakpoints, Breakpoint{
+				Line:    breakpoint.Line,
+				Message: "not part of the program being debugged",
+			})
+		}
+		return map[string]interface{}{"breakpoints": breakpoints}, nil
+	}
+
+	lines := make([]int, 0, len(request.Breakpoints))
+	for _, breakpoint := range request.Breakpoints {
+		lines = append(lines, breakpoint.Line)
+		breakpoints = append(breakpoints, Breakpoint{
+			Verified: true,
+			Line:     breakpoint.Line,
+			Source:   s.Source(),
+		})
+	}
+	s.Debugger.SetBreakpoints(lines)
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) Source() *Source {
+	return &Source{Name: filepath.Base(s.Program), Path: s.Program}
+}
+
+// Start runs the program on its own goroutine, reporting its output and
+// exit as events.
+func (s *Server) Start() error {
+	if s.Debugger == nil {
+		return errors.New("no program has been launched")
+	}
+
+	s.mu.Lock()
+	started := s.started
+	s.started = true
+	s.mu.Unlock()
+	if started {
+		return errors.New("the program is already running")
+	}
+
+	go func() {
+		defer close(s.Done)
+
+		exitCode := 0
+		err := s.Debugger.Run(s.Statements)
+		if err != nil && err != debugger.ErrQuit {
+			s.SendEvent("output", map[string]interface{}{"category": "stderr", "output": s.Describe(err)})
+			exitCode = 1
+		}
+		s.SendEvent("exited", map[string]interface{}{"exitCode": exitCode})
+		s.SendEvent("terminated", nil)
+	}()
+	return nil
+}
+
+// Stopped is called on the program's goroutine. It reports the stop and
+// waits for the client to resume.
+func (s *Server) Stopped(d *debugger.Debugger, reason string) debugger.Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return debugger.QUIT
+	}
+	s.stopped = true
+	s.handles = s.handles[:0]
+	s.mu.Unlock()
+
+	s.SendEvent("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          THREAD_ID,
+		"allThreadsStopped": true,
+	})
+	return <-s.Resume
+}
+
+// IsStopped reports whether the program is waiting in Stopped, which makes
+// its frames safe to read from the goroutine handling requests.
+func (s *Server) IsStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+func (s *Server) ResumeWith(request *Request, action debugger.Action, body interface{}) {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.stopped = false
+	s.mu.Unlock()
+
+	if !stopped {
+		s.Respond(request, nil, errors.New("the program is not stopped"))
+		return
+	}
+
+	s.Respond(request, body, nil)
+	s.Resume <- action
+}
+
+// Shutdown ends the program if it is still running and waits for it to
+// finish.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	started, stopped := s.started, s.stopped
+	s.stopped = false
+	s.terminating = true
+	s.mu.Unlock()
+
+	if !started {
+		return
+	}
+
+	s.Debugger.Terminate()
+	if stopped {
+		s.Resume <- debugger.QUIT
+	}
+	<-s.Done
+}
+
+// Frame finds a frame by the id given to it in StackTrace, which counts the
+// innermost frame as 1.
+func (s *Server) Frame(id int) (*debugger.Frame, error) {
+	if !s.IsStopped() {
+		return nil, errors.New("the program is not stopped")
+	}
+
+	frames := s.Debugger.Frames
+	if id < 1 || id > len(frames) {
+		return nil, fmt.Errorf("no frame %d", id)
+	}
+	return frames[len(frames)-id], nil
+}
+
+func (s *Server) StackTrace() (interface{}, error) {
+	if !s.IsStopped() {
+		return nil, errors.New("the program is not stopped")
+	}
+
+	frames := s.Debugger.Frames
+	stackFrames := make([]StackFrame, 0, len(frames))
+	for id := 1; id <= len(frames); id++ {
+		frame := frames[len(frames)-id]
+		stackFrames = append(stackFrames, StackFrame{
+			ID:     id,
+			Name:   frame.Name(),
+			Source: s.Source(),
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+
+	return map[string]interface{}{
+		"stackFrames": stackFrames,
+		"totalFrames": len(stackFrames),
+	}, nil
+}
+
+// Environments is the variables from environment From out to, but not
+// including, To.
+type Environments struct {
+	From *interpreter.Environment
+	To   *interpreter.Environment
+}
+
+// Reference gives value a variables reference for the client to ask about
+// later. References only last until the program resumes.
+func (s *Server) Reference(value interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles = append(s.handles, value)
+	return len(s.handles)
+}
+
+func (s *Server) Dereference(reference int) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reference < 1 || reference > len(s.handles) {
+		return nil, false
+	}
+	return s.handles[reference-1], true
+}
+
+func (s *Server) Scopes(arguments json.RawMessage) (interface{}, error) {
+	var request FrameArguments
+	err := json.Unmarshal(arguments, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := s.Frame(request.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	globals := s.Debugger.Interpreter.Globals
+	scopes := make([]Scope, 0, 2)
+	if frame.Env != globals {
+		scopes = append(scopes, Scope{
+			Name:               "Locals",
+			VariablesReference: s.Reference(Environments{From: frame.Env, To: globals}),
+		})
+	}
+	scopes = append(scopes, Scope{
+		Name:               "Globals",
+		VariablesReference: s.Reference(Environments{From: globals}),
+	})
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) Variables(arguments json.RawMessage) (interface{}, error) {
+	var request VariablesArguments
+	err := json.Unmarshal(arguments, &request)
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsStopped() {
+		return nil, errors.New("the program is not stopped")
+	}
+
+	value, ok := s.Dereference(request.VariablesReference)
+	if !ok {
+		return nil, fmt.Errorf("no variables reference %d", request.VariablesReference)
+	}
+
+	values := make(map[string]interface{})
+	switch value := value.(type) {
+	case Environments:
+		for environment := value.From; environment != value.To; environment = environment.Enclosing {
+			for name, value := range environment.Values {
+				if _, shadowed := values[name]; !shadowed {
+					values[name] = value
+				}
+			}
+		}
+	case *interpreter.LoxInstance:
+		values = value.Fields
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := make([]Variable, 0, len(names))
+	for _, name := range names {
+		variables = append(variables, s.Variable(name, values[name]))
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// Variable describes a value, giving instances a reference so that the
+// client can expand their fields.
+func (s *Server) Variable(name string, value interface{}) Variable {
+	variable := Variable{
+		Name:  name,
+		Value: interpreter.Describe(value),
+		Type:  TypeOf(value),
+	}
+	if instance, ok := value.(*interpreter.LoxInstance); ok {
+		variable.VariablesReference = s.Reference(instance)
+	}
+	return variable
+}
+
+func TypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case interpreter.Boolean:
+		return "boolean"
+	case interpreter.Integer, interpreter.Float, interpreter.BigInteger:
+		return "number"
+	case interpreter.String:
+		return "string"
+	case *interpreter.LoxInstance:
+		return "instance"
+	case *interpreter.LoxClass:
+		return "class"
+	default:
+		return "function"
+	}
+}
+
+// Evaluate looks up a variable, and any fields after it, in a frame.
+func (s *Server) Evaluate(arguments json.RawMessage) (interface{}, error) {
+	var request EvaluateArguments
+	err := json.Unmarshal(arguments, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.FrameID == 0 {
+		request.FrameID = 1
+	}
+	frame, err := s.Frame(request.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := frame.Resolve(request.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	variable := s.Variable(request.Expression, value)
+	return map[string]interface{}{
+		"result":             variable.Value,
+		"type":               variable.Type,
+		"variablesReference": variable.VariablesReference,
+	}, nil
+}
+
+// Output sends what the program prints to the client as output events.
+type Output struct {
+	Server   *Server
+	Category string
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.Server.SendEvent("output", map[string]interface{}{
+		"category": o.Category,
+		"output":   string(p),
+	})
+	return len(p), nil
+}
@@ -0,0 +1,404 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const source = `fun fib(n) {
+  if (n <= 1) return n;
+  var a = fib(n - 1);
+  return a + fib(n - 2);
+}
+var r = fib(3);
+print r;
+`
+
+// Message holds the fields of any message the server sends.
+type Message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// Client is a scripted DAP client talking to a server over pipes. Events
+// that arrive while it waits for a response are kept for WaitFor.
+type Client struct {
+	t      *testing.T
+	Reader *bufio.Reader
+	Writer io.Writer
+	Seq    int
+	Events []Message
+	Errors chan error
+}
+
+func start(t *testing.T) *Client {
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+
+	client := &Client{
+		t:      t,
+		Reader: bufio.NewReader(responseReader),
+		Writer: requestWriter,
+		Errors: make(chan error, 1),
+	}
+
+	go func() {
+		client.Errors <- NewServer(requests, responses).Serve()
+		responses.Close()
+	}()
+	return client
+}
+
+func (c *Client) Read() Message {
+	body, err := ReadMessage(c.Reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var message Message
+	err = json.Unmarshal(body, &message)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return message
+}
+
+// Request sends a request and waits for its response.
+func (c *Client) Request(command string, arguments interface{}) Message {
+	c.Seq++
+	request := map[string]interface{}{"seq": c.Seq, "type": "request", "command": command}
+	if arguments != nil {
+		request["arguments"] = arguments
+	}
+	err := WriteMessage(c.Writer, request)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		message := c.Read()
+		if message.Type == "event" {
+			c.Events = append(c.Events, message)
+			continue
+		}
+		if message.RequestSeq != c.Seq || message.Command != command {
+			c.t.Fatalf("expected a response to %s %d, got %+v", command, c.Seq, message)
+		}
+		return message
+	}
+}
+
+// Succeed sends a request, failing the test unless it succeeds, and decodes
+// the response's body into body.
+func (c *Client) Succeed(command string, arguments interface{}, body interface{}) {
+	c.t.Helper()
+	response := c.Request(command, arguments)
+	if !response.Success {
+		c.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+	if body != nil {
+		err := json.Unmarshal(response.Body, body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// WaitFor returns the next event with the given name, reading more messages
+// if it hasn't arrived yet.
+func (c *Client) WaitFor(event string) Message {
+	for {
+		for index, message := range c.Events {
+			if message.Event == event {
+				c.Events = append(c.Events[:index:index], c.Events[index+1:]...)
+				return message
+			}
+		}
+		message := c.Read()
+		if message.Type != "event" {
+			c.t.Fatalf("expected an event, got %+v", message)
+		}
+		c.Events = append(c.Events, message)
+	}
+}
+
+// Stopped waits for the program to stop and returns the reason.
+func (c *Client) Stopped() string {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	err := json.Unmarshal(c.WaitFor("stopped").Body, &body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return body.Reason
+}
+
+// Where lists the names and lines of the stack frames, innermost first.
+func (c *Client) Where() []string {
+	var body struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	c.Succeed("stackTrace", map[string]interface{}{"threadId": THREAD_ID}, &body)
+
+	frames := make([]string, 0)
+	for _, frame := range body.StackFrames {
+		frames = append(frames, frame.Name+":"+strconv.Itoa(frame.Line))
+	}
+	return frames
+}
+
+func (c *Client) Variables(reference int) []Variable {
+	var body struct {
+		Variables []Variable `json:"variables"`
+	}
+	c.Succeed("variables", VariablesArguments{VariablesReference: reference}, &body)
+	return body.Variables
+}
+
+func (c *Client) Evaluate(expression string) (string, int) {
+	var body struct {
+		Result             string `json:"result"`
+		VariablesReference int    `json:"variablesReference"`
+	}
+	c.Succeed("evaluate", EvaluateArguments{Expression: expression, FrameID: 1}, &body)
+	return body.Result, body.VariablesReference
+}
+
+// Launch starts a session debugging source, stopped at the given lines.
+func (c *Client) Launch(source string, stopOnEntry bool, lines ...int) string {
+	path := filepath.Join(c.t.TempDir(), "script.lox")
+	err := os.WriteFile(path, []byte(source), 0o644)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	c.Succeed("initialize", map[string]interface{}{"adapterID": "golox"}, nil)
+	c.WaitFor("initialized")
+	c.Succeed("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	breakpoints := make([]SourceBreakpoint, 0)
+	for _, line := range lines {
+		breakpoints = append(breakpoints, SourceBreakpoint{Line: line})
+	}
+	c.Succeed("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: breakpoints}, nil)
+	c.Succeed("configurationDone", nil, nil)
+	return path
+}
+
+// Finish waits for the program to end, returning what it printed, then
+// disconnects.
+func (c *Client) Finish() string {
+	c.WaitFor("exited")
+	c.WaitFor("terminated")
+
+	var output strings.Builder
+	for _, message := range c.Events {
+		var body struct {
+			Output string `json:"output"`
+		}
+		if message.Event == "output" && json.Unmarshal(message.Body, &body) == nil {
+			output.WriteString(body.Output)
+		}
+	}
+
+	c.Succeed("disconnect", nil, nil)
+	err := <-c.Errors
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestServer_Session(t *testing.T) {
+	c := start(t)
+	path := c.Launch(source, false, 3)
+
+	if reason := c.Stopped(); reason != "breakpoint" {
+		t.Fatalf("expected to stop at the breakpoint, stopped for %s", reason)
+	}
+	if where := c.Where(); !reflect.DeepEqual(where, []string{"fib:3", "<script>:6"}) {
+		t.Errorf("wrong stack at the breakpoint: %v", where)
+	}
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.Succeed("scopes", FrameArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes: %+v", scopes.Scopes)
+	}
+
+	locals := c.Variables(scopes.Scopes[0].VariablesReference)
+	if !reflect.DeepEqual(locals, []Variable{{Name: "n", Value: "3", Type: "number"}}) {
+		t.Errorf("wrong locals: %+v", locals)
+	}
+
+	globals := c.Variables(scopes.Scopes[1].VariablesReference)
+	found := false
+	for _, variable := range globals {
+		found = found || variable == Variable{Name: "fib", Value: "<fn fib>", Type: "function"}
+	}
+	if !found {
+		t.Errorf("expected fib among the globals: %+v", globals)
+	}
+
+	c.Succeed("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}}, nil)
+
+	c.Succeed("next", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := c.Stopped(); reason != "step" {
+		t.Fatalf("expected to stop after a step, stopped for %s", reason)
+	}
+	if where := c.Where(); !reflect.DeepEqual(where, []string{"fib:4", "<script>:6"}) {
+		t.Errorf("wrong stack after next: %v", where)
+	}
+	if a, _ := c.Evaluate("a"); a != "1" {
+		t.Errorf("expected a = 1, got %s", a)
+	}
+
+	c.Succeed("stepIn", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	c.Stopped()
+	if where := c.Where(); !reflect.DeepEqual(where, []string{"fib:2", "fib:4", "<script>:6"}) {
+		t.Errorf("wrong stack after stepIn: %v", where)
+	}
+
+	c.Succeed("stepOut", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	c.Stopped()
+	if where := c.Where(); !reflect.DeepEqual(where, []string{"<script>:7"}) {
+		t.Errorf("wrong stack after stepOut: %v", where)
+	}
+	if r, _ := c.Evaluate("r"); r != "2" {
+		t.Errorf("expected r = 2, got %s", r)
+	}
+
+	c.Succeed("continue", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if output := c.Finish(); output != "2\n" {
+		t.Errorf("expected the program to print 2, got %q", output)
+	}
+}
+
+func TestServer_Instance(t *testing.T) {
+	c := start(t)
+	c.Launch("class Point {}\nvar p = Point();\np.x = 1;\np.name = \"origin\";\nprint p.x;\n", false, 5)
+	c.Stopped()
+
+	value, reference := c.Evaluate("p")
+	if value != "Point instance" || reference == 0 {
+		t.Fatalf("expected an expandable instance, got %s with reference %d", value, reference)
+	}
+
+	fields := c.Variables(reference)
+	expected := []Variable{
+		{Name: "name", Value: `"origin"`, Type: "string"},
+		{Name: "x", Value: "1", Type: "number"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("wrong fields: %+v", fields)
+	}
+
+	if x, _ := c.Evaluate("p.x"); x != "1" {
+		t.Errorf("expected p.x = 1, got %s", x)
+	}
+	response := c.Request("evaluate", EvaluateArguments{Expression: "q", FrameID: 1})
+	if response.Success || response.Message != "undefined variable 'q'" {
+		t.Errorf("expected evaluating q to fail, got %+v", response)
+	}
+
+	c.Succeed("continue", nil, nil)
+	if output := c.Finish(); output != "1\n" {
+		t.Errorf("expected the program to print 1, got %q", output)
+	}
+}
+
+func TestServer_StopOnEntryAndDisconnect(t *testing.T) {
+	c := start(t)
+	c.Launch(source, true)
+
+	if reason := c.Stopped(); reason != "entry" {
+		t.Fatalf("expected to stop on entry, stopped for %s", reason)
+	}
+	if where := c.Where(); !reflect.DeepEqual(where, []string{"<script>:1"}) {
+		t.Errorf("wrong stack on entry: %v", where)
+	}
+
+	c.Succeed("disconnect", nil, nil)
+	err := <-c.Errors
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range c.Events {
+		if message.Event == "output" {
+			t.Errorf("expected the program not to run, got output %s", message.Body)
+		}
+	}
+}
+
+func TestServer_PauseAndDisconnectLoop(t *testing.T) {
+	c := start(t)
+	c.Launch("while (true) {}\n", false)
+
+	c.Succeed("pause", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := c.Stopped(); reason != "pause" {
+		t.Fatalf("expected to stop for the pause, stopped for %s", reason)
+	}
+	c.Succeed("continue", nil, nil)
+
+	c.Succeed("disconnect", nil, nil)
+	err := <-c.Errors
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_RuntimeError(t *testing.T) {
+	c := start(t)
+	c.Launch("print 1;\nprint -\"a\";\n", false)
+
+	output := c.Finish()
+	if !strings.HasPrefix(output, "1\n") || !strings.Contains(output, "operand must be a number") {
+		t.Errorf("expected the error after the output, got %q", output)
+	}
+}
+
+func TestServer_LaunchErrors(t *testing.T) {
+	c := start(t)
+
+	response := c.Request("launch", LaunchArguments{Program: filepath.Join(t.TempDir(), "missing.lox")})
+	if response.Success {
+		t.Error("expected launching a missing file to fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.lox")
+	err := os.WriteFile(path, []byte("print 1 +;\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response = c.Request("launch", LaunchArguments{Program: path})
+	if response.Success || !strings.Contains(response.Message, "broken.lox:1") {
+		t.Errorf("expected the parse error to be reported, got %+v", response)
+	}
+
+	response = c.Request("stackTrace", nil)
+	if response.Success {
+		t.Error("expected stackTrace to fail with nothing running")
+	}
+
+	c.Succeed("disconnect", nil, nil)
+	if err := <-c.Errors; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,234 @@
+package debugger
+
+import (
+	"fmt"
+	"golox/pkg/lox/editor"
+	"golox/pkg/lox/interpreter"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Console is a command-line frontend. Each time the script stops it shows
+// where, then reads commands until one of them resumes execution.
+type Console struct {
+	Lines  *editor.Editor
+	Writer io.Writer
+	Path   string
+	Source []string
+
+	// Selected is the frame that frame-relative commands look at, counting
+	// out from the innermost at 0.
+	Selected int
+}
+
+func NewConsole(lines *editor.Editor, writer io.Writer, path string, source string) *Console {
+	return &Console{
+		Lines:  lines,
+		Writer: writer,
+		Path:   path,
+		Source: strings.Split(strings.TrimSuffix(source, "\n"), "\n"),
+	}
+}
+
+type ConsoleCommand struct {
+	Names []string
+	Args  string
+	Help  string
+	Run   func(c *Console, d *Debugger, argument string) (Action, bool)
+}
+
+var ConsoleCommands = []ConsoleCommand{
+	{[]string{"break", "b"}, "[line]", "set a breakpoint, or list them", (*Console).Break},
+	{[]string{"clear"}, "line", "remove a breakpoint", (*Console).Clear},
+	{[]string{"continue", "c"}, "", "run to the next breakpoint", resume(CONTINUE)},
+	{[]string{"step", "s"}, "", "run to the next line, stepping into calls", resume(STEP_INTO)},
+	{[]string{"next", "n"}, "", "run to the next line, stepping over calls", resume(STEP_OVER)},
+	{[]string{"out", "o"}, "", "run until the current function returns", resume(STEP_OUT)},
+	{[]string{"backtrace", "bt"}, "", "show the call stack", (*Console).Backtrace},
+	{[]string{"frame", "f"}, "n", "select frame n of the call stack", (*Console).Frame},
+	{[]string{"vars", "v"}, "", "list the variables in the selected frame", (*Console).Vars},
+	{[]string{"globals", "g"}, "", "list the global variables", (*Console).Globals},
+	{[]string{"print", "p"}, "name[.field...]", "show a variable in the selected frame", (*Console).Print},
+	{[]string{"list", "l"}, "", "show the source around the selected frame's line", (*Console).List},
+	{[]string{"quit", "q"}, "", "stop debugging", resume(QUIT)},
+}
+
+func resume(action Action) func(c *Console, d *Debugger, argument string) (Action, bool) {
+	return func(c *Console, d *Debugger, argument string) (Action, bool) {
+		return action, true
+	}
+}
+
+func (c *Console) Stopped(d *Debugger, reason string) Action {
+	c.Selected = 0
+	top := d.Top()
+	fmt.Fprintf(c.Writer, "stopped at %s in %s, %s:%d\n", reason, top.Name(), c.Path, top.Line)
+	c.ShowLine(top.Line, true)
+
+	for {
+		line, err := c.Lines.ReadLine("(debug) ")
+		if err == editor.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			return QUIT
+		}
+
+		name, argument, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if name == "" {
+			continue
+		}
+
+		action, resumed := c.Run(d, name, strings.TrimSpace(argument))
+		if resumed {
+			return action
+		}
+	}
+}
+
+func (c *Console) Run(d *Debugger, name string, argument string) (Action, bool) {
+	for _, command := range ConsoleCommands {
+		for _, alias := range command.Names {
+			if alias == name {
+				return command.Run(c, d, argument)
+			}
+		}
+	}
+
+	fmt.Fprintf(c.Writer, "unknown command '%s'; available commands:\n", name)
+	for _, command := range ConsoleCommands {
+		usage := strings.TrimSpace(strings.Join(command.Names, ", ") + " " + command.Args)
+		fmt.Fprintf(c.Writer, "  %-24s %s\n", usage, command.Help)
+	}
+	return CONTINUE, false
+}
+
+// ShowLine prints a line of the script with its number, marking the line
+// execution is stopped at.
+func (c *Console) ShowLine(line int, current bool) {
+	if line < 1 || line > len(c.Source) {
+		return
+	}
+	marker := " "
+	if current {
+		marker = ">"
+	}
+	fmt.Fprintf(c.Writer, "%s %4d | %s\n", marker, line, c.Source[line-1])
+}
+
+func (c *Console) SelectedFrame(d *Debugger) *Frame {
+	return d.Frames[len(d.Frames)-1-c.Selected]
+}
+
+func (c *Console) Break(d *Debugger, argument string) (Action, bool) {
+	if argument == "" {
+		for _, line := range d.Breakpoints() {
+			c.ShowLine(line, false)
+		}
+		return CONTINUE, false
+	}
+
+	line, err := strconv.Atoi(argument)
+	if err != nil || line < 1 || line > len(c.Source) {
+		fmt.Fprintf(c.Writer, "no line '%s' in %s\n", argument, c.Path)
+		return CONTINUE, false
+	}
+
+	d.SetBreakpoint(line)
+	fmt.Fprintf(c.Writer, "breakpoint at %s:%d\n", c.Path, line)
+	return CONTINUE, false
+}
+
+func (c *Console) Clear(d *Debugger, argument string) (Action, bool) {
+	line, err := strconv.Atoi(argument)
+	if err != nil || !d.ClearBreakpoint(line) {
+		fmt.Fprintf(c.Writer, "no breakpoint at line '%s'\n", argument)
+	}
+	return CONTINUE, false
+}
+
+func (c *Console) Backtrace(d *Debugger, argument string) (Action, bool) {
+	for index := range d.Frames {
+		frame := d.Frames[len(d.Frames)-1-index]
+		marker := " "
+		if index == c.Selected {
+			marker = "*"
+		}
+		fmt.Fprintf(c.Writer, "%s #%d %s at %s:%d\n", marker, index, frame.Name(), c.Path, frame.Line)
+	}
+	return CONTINUE, false
+}
+
+func (c *Console) Frame(d *Debugger, argument string) (Action, bool) {
+	index, err := strconv.Atoi(argument)
+	if err != nil || index < 0 || index >= len(d.Frames) {
+		fmt.Fprintf(c.Writer, "no frame '%s'; frames are numbered 0 to %d\n", argument, len(d.Frames)-1)
+		return CONTINUE, false
+	}
+
+	c.Selected = index
+	frame := c.SelectedFrame(d)
+	fmt.Fprintf(c.Writer, "#%d %s at %s:%d\n", index, frame.Name(), c.Path, frame.Line)
+	c.ShowLine(frame.Line, true)
+	return CONTINUE, false
+}
+
+// Vars lists the selected frame's scopes from the innermost out, stopping
+// short of the globals.
+func (c *Console) Vars(d *Debugger, argument string) (Action, bool) {
+	globals := d.Interpreter.Globals
+	found := false
+	for environment := c.SelectedFrame(d).Env; environment != nil && environment != globals; environment = environment.Enclosing {
+		c.PrintValues(environment.Values)
+		found = found || len(environment.Values) > 0
+	}
+	if !found {
+		fmt.Fprintln(c.Writer, "no local variables; see 'globals'")
+	}
+	return CONTINUE, false
+}
+
+func (c *Console) Globals(d *Debugger, argument string) (Action, bool) {
+	c.PrintValues(d.Interpreter.Globals.Values)
+	return CONTINUE, false
+}
+
+func (c *Console) PrintValues(values map[string]interface{}) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(c.Writer, "%s = %s\n", name, interpreter.Describe(values[name]))
+	}
+}
+
+// Print shows a variable, following any field names after it.
+func (c *Console) Print(d *Debugger, argument string) (Action, bool) {
+	value, err := c.SelectedFrame(d).Resolve(argument)
+	if err != nil {
+		fmt.Fprintln(c.Writer, err)
+		return CONTINUE, false
+	}
+
+	if instance, ok := value.(*interpreter.LoxInstance); ok {
+		fmt.Fprintln(c.Writer, interpreter.Describe(instance))
+		c.PrintValues(instance.Fields)
+		return CONTINUE, false
+	}
+
+	fmt.Fprintf(c.Writer, "%s = %s\n", argument, interpreter.Describe(value))
+	return CONTINUE, false
+}
+
+func (c *Console) List(d *Debugger, argument string) (Action, bool) {
+	current := c.SelectedFrame(d).Line
+	for line := current - 3; line <= current+3; line++ {
+		c.ShowLine(line, line == current)
+	}
+	return CONTINUE, false
+}
@@ -0,0 +1,291 @@
+// Package debugger runs scripts on the tree-walk interpreter under the
+// control of a frontend, stopping at breakpoints and stepping through them
+// line by line.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/interpreter"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Action int
+
+const (
+	CONTINUE Action = iota
+	STEP_INTO
+	STEP_OVER
+	STEP_OUT
+	QUIT
+)
+
+// ErrQuit is returned by Run when the frontend ends the session before the
+// script finishes.
+var ErrQuit = errors.New("debugging session ended")
+
+// Frontend is told whenever execution stops and answers with how to carry
+// on. The reason is "entry", "breakpoint", "step" or "pause".
+type Frontend interface {
+	Stopped(d *Debugger, reason string) Action
+}
+
+// Frame is a function call in progress, or the script's top level when
+// Function is nil. Env and Line are where the frame is executing: for the
+// innermost frame the statement about to run, and for the others the call
+// they are waiting on.
+type Frame struct {
+	Function *interpreter.LoxFunction
+	Env      *interpreter.Environment
+	Line     int
+}
+
+func (f *Frame) Name() string {
+	if f.Function == nil {
+		return "<script>"
+	}
+	return f.Function.Declaration.Name.Lexeme
+}
+
+// Lookup finds a variable in the frame's scope, or in any scope enclosing it.
+func (f *Frame) Lookup(name string) (interface{}, bool) {
+	for environment := f.Env; environment != nil; environment = environment.Enclosing {
+		value, ok := environment.Values[name]
+		if ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Resolve looks up a variable followed by any fields of it, as in "a.b.c".
+func (f *Frame) Resolve(path string) (interface{}, error) {
+	names := strings.Split(path, ".")
+
+	value, ok := f.Lookup(names[0])
+	if !ok {
+		return nil, fmt.Errorf("undefined variable '%s'", names[0])
+	}
+
+	for _, field := range names[1:] {
+		instance, ok := value.(*interpreter.LoxInstance)
+		if ok {
+			value, ok = instance.Fields[field]
+		}
+		if !ok {
+			return nil, fmt.Errorf("undefined field '%s'", field)
+		}
+	}
+	return value, nil
+}
+
+// Debugger is driven from the goroutine running the script, except for the
+// breakpoints, Pause and Terminate, which a frontend may use from another
+// goroutine while the script runs.
+type Debugger struct {
+	Interpreter *interpreter.Interpreter
+	Frontend    Frontend
+	StopOnEntry bool
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       bool
+	terminate   bool
+
+	// Frames is the call stack, outermost first.
+	Frames []*Frame
+
+	Action     Action
+	StepDepth  int
+	LastLine   int
+	LastDepth  int
+	LastOffset int
+	Started    bool
+}
+
+func NewDebugger(i *interpreter.Interpreter, frontend Frontend) *Debugger {
+	return &Debugger{
+		Interpreter: i,
+		Frontend:    frontend,
+		breakpoints: make(map[int]bool),
+		Frames:      make([]*Frame, 0),
+	}
+}
+
+// Run executes resolved statements, stopping as the breakpoints and the
+// frontend's actions direct. Statements shouldn't be optimized, so that every
+// line the script was written with is still there to stop on.
+func (d *Debugger) Run(statements []ast.Statement) (err error) {
+	d.Frames = []*Frame{{Env: d.Interpreter.Globals}}
+	d.Action = CONTINUE
+	d.LastLine, d.LastDepth, d.LastOffset = 0, 0, -1
+	d.Started = false
+
+	d.Interpreter.Hook = d
+	defer func() {
+		d.Interpreter.Hook = nil
+		d.Interpreter.Env = d.Interpreter.Globals
+
+		r := recover()
+		if r == nil {
+			return
+		}
+		if r != ErrQuit {
+			panic(r)
+		}
+		err = ErrQuit
+	}()
+
+	return d.Interpreter.Interpret(statements)
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+func (d *Debugger) ClearBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ok := d.breakpoints[line]
+	delete(d.breakpoints, line)
+	return ok
+}
+
+// SetBreakpoints replaces every breakpoint with the given lines.
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Breakpoints lists the breakpoint lines in order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Pause stops the script at the next statement it runs.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// Terminate ends the script at the next statement it runs, as if the
+// frontend had answered QUIT.
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.terminate = true
+}
+
+func (d *Debugger) Top() *Frame {
+	return d.Frames[len(d.Frames)-1]
+}
+
+// Statement stops before a statement when a breakpoint or the current step
+// says to, unless it follows the last statement on the same line of the same
+// frame. Coming back to an earlier statement, as a loop does, stops again.
+// Blocks are passed over for the first statement inside them, unless the
+// frontend asked to pause or terminate: a loop such as 'while (true) {}' runs
+// nothing but its block.
+func (d *Debugger) Statement(i *interpreter.Interpreter, stmt ast.Statement) {
+	start := stmt.Span().Start
+	line := start.Line
+
+	d.mu.Lock()
+	terminate, pause, breakpoint := d.terminate, d.pause, d.breakpoints[line]
+	d.pause = false
+	d.mu.Unlock()
+
+	if terminate {
+		panic(ErrQuit)
+	}
+	if _, ok := stmt.(ast.Block); ok && !pause {
+		return
+	}
+
+	depth := len(d.Frames)
+	top := d.Top()
+	top.Env = i.Env
+	top.Line = line
+
+	later := line == d.LastLine && depth == d.LastDepth && start.Offset > d.LastOffset
+	d.LastLine, d.LastDepth, d.LastOffset = line, depth, start.Offset
+	if later && !pause {
+		return
+	}
+
+	reason := ""
+	switch d.Action {
+	case STEP_INTO:
+		reason = "step"
+	case STEP_OVER:
+		if depth <= d.StepDepth {
+			reason = "step"
+		}
+	case STEP_OUT:
+		if depth < d.StepDepth {
+			reason = "step"
+		}
+	}
+	if breakpoint {
+		reason = "breakpoint"
+	}
+	if pause {
+		reason = "pause"
+	}
+	if !d.Started {
+		d.Started = true
+		if d.StopOnEntry {
+			reason = "entry"
+		}
+	}
+
+	if reason != "" {
+		d.Stop(reason)
+	}
+}
+
+func (d *Debugger) Stop(reason string) {
+	action := d.Frontend.Stopped(d, reason)
+	if action == QUIT {
+		panic(ErrQuit)
+	}
+	d.Action = action
+	d.StepDepth = len(d.Frames)
+}
+
+// Enter pushes a frame for the function. A tail call takes the place of the
+// function that made it, so stepping over a line of that function, as with
+// 'return g(x);', waits for the frame's caller rather than stopping in g.
+func (d *Debugger) Enter(i *interpreter.Interpreter, function *interpreter.LoxFunction, tail bool) {
+	depth := len(d.Frames) + 1
+	if tail && d.Action == STEP_OVER && d.StepDepth >= depth {
+		d.StepDepth = depth - 1
+	}
+
+	d.Top().Env = i.Env
+	d.Frames = append(d.Frames, &Frame{
+		Function: function,
+		Line:     function.Declaration.Name.Line,
+	})
+}
+
+func (d *Debugger) Leave(i *interpreter.Interpreter, function *interpreter.LoxFunction) {
+	d.Frames = d.Frames[:len(d.Frames)-1]
+}
@@ -0,0 +1,317 @@
+package debugger
+
+import (
+	"bytes"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/editor"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const source = `fun fib(n) {
+  if (n <= 1) return n;
+  var a = fib(n - 1);
+  return a + fib(n - 2);
+}
+var r = fib(3);
+print r;
+`
+
+// Script is a frontend that records where execution stopped and answers
+// with a fixed list of actions, continuing once they run out.
+type Script struct {
+	Actions []Action
+	Stops   []string
+}
+
+func (s *Script) Stopped(d *Debugger, reason string) Action {
+	names := make([]string, 0)
+	for _, frame := range d.Frames {
+		names = append(names, frame.Name())
+	}
+	s.Stops = append(s.Stops, reason+" "+strings.Join(names, ">")+":"+strconv.Itoa(d.Top().Line))
+
+	if len(s.Actions) == 0 {
+		return CONTINUE
+	}
+	action := s.Actions[0]
+	s.Actions = s.Actions[1:]
+	return action
+}
+
+func prepare(t *testing.T, source string) (*interpreter.Interpreter, []ast.Statement, *bytes.Buffer) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.Writer = &out
+	i.Resolve(locals)
+	return i, statements, &out
+}
+
+func debug(t *testing.T, script *Script, breakpoints ...int) (*Debugger, string) {
+	i, statements, out := prepare(t, source)
+
+	d := NewDebugger(i, script)
+	d.StopOnEntry = true
+	for _, line := range breakpoints {
+		d.SetBreakpoint(line)
+	}
+
+	err := d.Run(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, out.String()
+}
+
+func expectStops(t *testing.T, script *Script, expected ...string) {
+	if strings.Join(script.Stops, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected stops\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(script.Stops, "\n"))
+	}
+}
+
+func TestDebugger_Breakpoints(t *testing.T) {
+	script := &Script{}
+	d, out := debug(t, script, 4)
+
+	expectStops(t, script,
+		"entry <script>:1",
+		"breakpoint <script>>fib>fib:4",
+		"breakpoint <script>>fib:4",
+	)
+
+	if out != "2\n" {
+		t.Fatalf("expected the script to run to completion, got %q", out)
+	}
+	if d.Interpreter.Hook != nil {
+		t.Fatal("expected the hook to be removed")
+	}
+}
+
+func TestDebugger_StepInto(t *testing.T) {
+	script := &Script{Actions: []Action{STEP_INTO, STEP_INTO, STEP_INTO, STEP_INTO, STEP_INTO}}
+	debug(t, script)
+
+	expectStops(t, script,
+		"entry <script>:1",
+		"step <script>:6",
+		"step <script>>fib:2",
+		"step <script>>fib:3",
+		"step <script>>fib>fib:2",
+		"step <script>>fib>fib:3",
+	)
+}
+
+func TestDebugger_StepOverAndOut(t *testing.T) {
+	script := &Script{Actions: []Action{STEP_OVER, STEP_OVER, STEP_OUT, STEP_OVER, STEP_OUT, STEP_OUT}}
+	debug(t, script, 3)
+
+	expectStops(t, script,
+		"entry <script>:1",
+		"step <script>:6",
+		"breakpoint <script>>fib:3",
+		"breakpoint <script>>fib>fib:3",
+		"step <script>>fib>fib:4",
+		"step <script>>fib:4",
+		"step <script>:7",
+	)
+
+	// Stepping over or out of a tail call carries on in the caller of the
+	// function that made it, since the callee has taken over its frame.
+	tail := `fun g(y) {
+  return y + 1;
+}
+fun f(x) {
+  var y = x * 2;
+  return g(y);
+}
+print f(1);
+print "end";
+`
+	i, statements, out := prepare(t, tail)
+	script = &Script{Actions: []Action{STEP_OVER, CONTINUE, STEP_OUT}}
+	d := NewDebugger(i, script)
+	d.SetBreakpoint(6)
+	err := d.Run(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, statements, _ = prepare(t, tail)
+	d = NewDebugger(i, script)
+	d.SetBreakpoint(2)
+	err = d.Run(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStops(t, script,
+		"breakpoint <script>>f:6",
+		"step <script>:9",
+		"breakpoint <script>>g:2",
+		"step <script>:9",
+	)
+	if out.String() != "3\nend\n" {
+		t.Fatalf("expected the script to run to completion, got %q", out.String())
+	}
+}
+
+func TestDebugger_Loop(t *testing.T) {
+	i, statements, out := prepare(t, "var i = 0;\nwhile (i < 3) {\n  i = i + 1;\n}\nfor (var j = 0; j < 2; j = j + 1) print j;\n")
+	script := &Script{}
+	d := NewDebugger(i, script)
+	d.SetBreakpoint(3)
+	d.SetBreakpoint(5)
+
+	err := d.Run(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStops(t, script,
+		"breakpoint <script>:3",
+		"breakpoint <script>:3",
+		"breakpoint <script>:3",
+		// Once as the for loop starts, then as each later iteration comes
+		// back to the body.
+		"breakpoint <script>:5",
+		"breakpoint <script>:5",
+		"breakpoint <script>:5",
+	)
+	if out.String() != "0\n1\n" {
+		t.Fatalf("expected the loops to run to completion, got %q", out.String())
+	}
+}
+
+func TestDebugger_StepThroughLoop(t *testing.T) {
+	i, statements, _ := prepare(t, "var i = 0;\nwhile (i < 2) {\n  i = i + 1;\n}\nprint i;\n")
+	script := &Script{Actions: []Action{STEP_OVER, STEP_OVER, STEP_OVER, STEP_OVER}}
+	d := NewDebugger(i, script)
+	d.StopOnEntry = true
+
+	err := d.Run(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStops(t, script,
+		"entry <script>:1",
+		"step <script>:2",
+		"step <script>:3",
+		"step <script>:3",
+		"step <script>:5",
+	)
+}
+
+// Signal is a frontend that reports each stop on a channel and continues.
+type Signal chan string
+
+func (s Signal) Stopped(d *Debugger, reason string) Action {
+	s <- reason
+	return CONTINUE
+}
+
+func TestDebugger_PauseAndTerminate(t *testing.T) {
+	// The loop runs nothing but its empty block, which still has to stop
+	// for a pause and end for Terminate.
+	i, statements, _ := prepare(t, "while (true) {}\n")
+	stops := make(Signal, 1)
+	d := NewDebugger(i, stops)
+
+	done := make(chan error, 1)
+	go func() { done <- d.Run(statements) }()
+
+	time.Sleep(10 * time.Millisecond)
+	d.Pause()
+	select {
+	case reason := <-stops:
+		if reason != "pause" {
+			t.Fatalf("expected to stop for the pause, stopped for %s", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Pause to stop the loop")
+	}
+
+	d.Terminate()
+	select {
+	case err := <-done:
+		if err != ErrQuit {
+			t.Fatalf("expected the session to end, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Terminate to end the loop")
+	}
+}
+
+func TestDebugger_Quit(t *testing.T) {
+	i, statements, out := prepare(t, source)
+	d := NewDebugger(i, &Script{Actions: []Action{QUIT}})
+	d.SetBreakpoint(7)
+
+	err := d.Run(statements)
+	if err != ErrQuit {
+		t.Fatal("expected the session to end")
+	}
+	if out.Len() != 0 {
+		t.Fatal("expected the script to stop before printing")
+	}
+	if i.Env != i.Globals || i.Hook != nil {
+		t.Fatal("expected the interpreter to be usable again")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	i, statements, _ := prepare(t, source)
+
+	input := "b 3\nc\nbt\nv\nf 1\nv\np fib\np n\nout\nq\n"
+	var out bytes.Buffer
+	console := NewConsole(editor.NewEditor(strings.NewReader(input), io.Discard), &out, "fib.lox", source)
+	d := NewDebugger(i, console)
+	d.StopOnEntry = true
+
+	err := d.Run(statements)
+	if err != ErrQuit {
+		t.Fatal("expected the session to end")
+	}
+
+	expected := `stopped at entry in <script>, fib.lox:1
+>    1 | fun fib(n) {
+breakpoint at fib.lox:3
+stopped at breakpoint in fib, fib.lox:3
+>    3 |   var a = fib(n - 1);
+* #0 fib at fib.lox:3
+  #1 <script> at fib.lox:6
+n = 3
+#1 <script> at fib.lox:6
+>    6 | var r = fib(3);
+no local variables; see 'globals'
+fib = <fn fib>
+undefined variable 'n'
+stopped at breakpoint in fib, fib.lox:3
+>    3 |   var a = fib(n - 1);
+`
+	if out.String() != expected {
+		t.Fatalf("unexpected console output\n%s", out.String())
+	}
+}
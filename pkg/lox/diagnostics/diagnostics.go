@@ -0,0 +1,127 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"golox/pkg/lox/token"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	reset = "\033[0m"
+	bold  = "\033[1m"
+	red   = "\033[31m"
+	blue  = "\033[34m"
+)
+
+// Diagnostic is an error that knows which part of the source it refers to.
+// ScanError, ParseError, ResolveError and RuntimeError all implement it.
+type Diagnostic interface {
+	error
+	Span() token.Span
+	Reason() string
+}
+
+type Printer struct {
+	Writer io.Writer
+	Color  bool
+}
+
+func NewPrinter(w io.Writer) *Printer {
+	color := false
+	if f, ok := w.(*os.File); ok {
+		color = IsTerminal(f) && os.Getenv("NO_COLOR") == ""
+	}
+
+	return &Printer{
+		Writer: w,
+		Color:  color,
+	}
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Print renders err against source. Joined errors, such as the list returned by
+// parser.Parse, are printed one after another.
+func (p *Printer) Print(file string, source string, err error) {
+	if list, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range list.Unwrap() {
+			p.Print(file, source, e)
+		}
+		return
+	}
+
+	var diagnostic Diagnostic
+	if !errors.As(err, &diagnostic) {
+		fmt.Fprintf(p.Writer, "%s: %s %s\n", p.paint(bold, file), p.paint(red, "error:"), err)
+		return
+	}
+
+	span := diagnostic.Span()
+	fmt.Fprintf(p.Writer, "%s %s %s\n", p.paint(bold, fmt.Sprintf("%s:%d:%d:", file, span.Start.Line, span.Start.Column)), p.paint(red, "error:"), p.paint(bold, diagnostic.Reason()))
+
+	line := Line(source, span.Start.Line)
+	number := fmt.Sprintf("%d", span.Start.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	fmt.Fprintf(p.Writer, "%s %s\n", gutter, p.paint(blue, "|"))
+	fmt.Fprintf(p.Writer, "%s %s %s\n", p.paint(blue, number), p.paint(blue, "|"), line)
+	fmt.Fprintf(p.Writer, "%s %s %s\n", gutter, p.paint(blue, "|"), p.paint(red, Underline(line, span)))
+}
+
+func (p *Printer) paint(color string, text string) string {
+	if !p.Color {
+		return text
+	}
+	return color + text + reset
+}
+
+// Line returns the 1-based line of source without its line terminator.
+func Line(source string, number int) string {
+	lines := strings.Split(source, "\n")
+	if number < 1 || number > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[number-1], "\r")
+}
+
+// Underline returns a "^~~~" marker for the part of line covered by span. Spans
+// that continue onto later lines are underlined to the end of the first line.
+func Underline(line string, span token.Span) string {
+	start := span.Start.Column - 1
+	if start > len(line) {
+		start = len(line)
+	}
+
+	end := len(line)
+	if span.End.Line == span.Start.Line {
+		end = span.End.Column - 1
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+
+	var builder strings.Builder
+	for _, c := range []byte(line[:start]) {
+		if c == '\t' {
+			builder.WriteByte('\t')
+		} else {
+			builder.WriteByte(' ')
+		}
+	}
+
+	builder.WriteByte('^')
+	if end-start > 1 {
+		builder.WriteString(strings.Repeat("~", end-start-1))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,56 @@
+package diagnostics
+
+import (
+	"bytes"
+	"errors"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/scanner"
+	"testing"
+)
+
+func TestPrinter_Print_Snippet(t *testing.T) {
+	source := "var a = 1;\nprint a +;\n"
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parser.Parse(tokens)
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	var out bytes.Buffer
+	printer := &Printer{Writer: &out}
+	printer.Print("test.lox", source, err)
+
+	expected := "test.lox:2:10: error: expect expression\n" +
+		"  |\n" +
+		"2 | print a +;\n" +
+		"  |          ^\n"
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPrinter_Print_Plain(t *testing.T) {
+	var out bytes.Buffer
+	printer := &Printer{Writer: &out}
+	printer.Print("test.lox", "", errors.New("boom"))
+
+	if out.String() != "test.lox: error: boom\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestUnderline(t *testing.T) {
+	tokens, err := scanner.Scan("print\tnope;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	underline := Underline("print\tnope;", tokens[1].Span())
+	if underline != "     \t^~~~" {
+		t.Fatalf("unexpected underline %q", underline)
+	}
+}
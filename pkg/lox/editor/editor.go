@@ -0,0 +1,586 @@
+// Package editor reads lines from a terminal with cursor movement, history,
+// reverse search and tab completion. Input that isn't a terminal is read a
+// plain line at a time.
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HISTORY_MAX is the number of lines kept in memory and in the history file.
+const HISTORY_MAX = 1000
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C is pressed.
+var ErrInterrupted = errors.New("interrupted")
+
+type Key rune
+
+const (
+	KEY_CTRL_A    Key = 1
+	KEY_CTRL_B    Key = 2
+	KEY_CTRL_C    Key = 3
+	KEY_CTRL_D    Key = 4
+	KEY_CTRL_E    Key = 5
+	KEY_CTRL_F    Key = 6
+	KEY_CTRL_G    Key = 7
+	KEY_CTRL_H    Key = 8
+	KEY_TAB       Key = 9
+	KEY_LINE_FEED Key = 10
+	KEY_CTRL_K    Key = 11
+	KEY_CTRL_L    Key = 12
+	KEY_ENTER     Key = 13
+	KEY_CTRL_N    Key = 14
+	KEY_CTRL_P    Key = 16
+	KEY_CTRL_R    Key = 18
+	KEY_CTRL_U    Key = 21
+	KEY_CTRL_W    Key = 23
+	KEY_ESCAPE    Key = 27
+	KEY_BACKSPACE Key = 127
+)
+
+// Keys sent as escape sequences are given negative values so that they can't
+// be mistaken for a character.
+const (
+	KEY_UP Key = -(iota + 1)
+	KEY_DOWN
+	KEY_RIGHT
+	KEY_LEFT
+	KEY_HOME
+	KEY_END
+	KEY_DELETE
+	KEY_WORD_LEFT
+	KEY_WORD_RIGHT
+	KEY_UNKNOWN
+)
+
+type Editor struct {
+	Reader *bufio.Reader
+	Writer io.Writer
+
+	// Fd is the terminal put into raw mode while a line is edited, or -1 when
+	// Terminal is set without a real terminal to switch, as in tests.
+	Fd       int
+	Terminal bool
+
+	History     []string
+	HistoryFile string
+
+	// Complete returns the words that the word before the cursor, prefix, can
+	// be completed to when Tab is pressed.
+	Complete func(prefix string) []string
+
+	Prompt       string
+	Line         []rune
+	Cursor       int
+	HistoryIndex int
+	Pending      string
+}
+
+func NewEditor(in io.Reader, out io.Writer) *Editor {
+	editor := &Editor{
+		Reader:  bufio.NewReader(in),
+		Writer:  out,
+		Fd:      -1,
+		History: make([]string, 0),
+	}
+
+	file, ok := in.(*os.File)
+	if ok && IsTerminal(int(file.Fd())) {
+		editor.Fd = int(file.Fd())
+		editor.Terminal = true
+	}
+
+	return editor
+}
+
+// ReadLine returns the next line of input without its line ending. It
+// returns io.EOF once input runs out or Ctrl-D is pressed on an empty line.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.Terminal {
+		return e.ReadPlain(prompt)
+	}
+
+	if e.Fd >= 0 {
+		state, err := MakeRaw(e.Fd)
+		if err != nil {
+			return e.ReadPlain(prompt)
+		}
+		defer SetState(e.Fd, state)
+	}
+
+	line, err := e.Edit(prompt)
+	if err == nil {
+		e.AddHistory(line)
+	}
+	return line, err
+}
+
+func (e *Editor) ReadPlain(prompt string) (string, error) {
+	fmt.Fprint(e.Writer, prompt)
+
+	line, err := e.Reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
+
+// Edit reads keys until the line is entered. The terminal must already be in
+// raw mode.
+func (e *Editor) Edit(prompt string) (string, error) {
+	e.Prompt = prompt
+	e.Line = make([]rune, 0)
+	e.Cursor = 0
+	e.HistoryIndex = len(e.History)
+	e.Pending = ""
+	e.Refresh()
+
+	for {
+		key, err := e.ReadKey()
+		if err != nil {
+			if err == io.EOF && len(e.Line) > 0 {
+				e.Write("\r\n")
+				return string(e.Line), nil
+			}
+			return "", err
+		}
+
+		if key == KEY_CTRL_R {
+			key, err = e.Search()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		line, done, err := e.Handle(key)
+		if done {
+			return line, err
+		}
+	}
+}
+
+// Handle applies a key to the line, reporting done once the line has been
+// entered or abandoned.
+func (e *Editor) Handle(key Key) (string, bool, error) {
+	switch key {
+	case KEY_ENTER, KEY_LINE_FEED:
+		e.Write("\r\n")
+		return string(e.Line), true, nil
+	case KEY_CTRL_C:
+		e.Write("^C\r\n")
+		return "", true, ErrInterrupted
+	case KEY_CTRL_D:
+		if len(e.Line) == 0 {
+			e.Write("\r\n")
+			return "", true, io.EOF
+		}
+		e.DeleteRange(e.Cursor, e.Cursor+1)
+	case KEY_BACKSPACE, KEY_CTRL_H:
+		e.DeleteRange(e.Cursor-1, e.Cursor)
+	case KEY_DELETE:
+		e.DeleteRange(e.Cursor, e.Cursor+1)
+	case KEY_LEFT, KEY_CTRL_B:
+		e.MoveTo(e.Cursor - 1)
+	case KEY_RIGHT, KEY_CTRL_F:
+		e.MoveTo(e.Cursor + 1)
+	case KEY_HOME, KEY_CTRL_A:
+		e.MoveTo(0)
+	case KEY_END, KEY_CTRL_E:
+		e.MoveTo(len(e.Line))
+	case KEY_WORD_LEFT:
+		e.MoveTo(e.WordStart())
+	case KEY_WORD_RIGHT:
+		e.MoveTo(e.WordEnd())
+	case KEY_CTRL_K:
+		e.DeleteRange(e.Cursor, len(e.Line))
+	case KEY_CTRL_U:
+		e.DeleteRange(0, e.Cursor)
+	case KEY_CTRL_W:
+		e.DeleteRange(e.WordStart(), e.Cursor)
+	case KEY_CTRL_L:
+		e.Write("\x1b[H\x1b[2J")
+		e.Refresh()
+	case KEY_UP, KEY_CTRL_P:
+		e.MoveHistory(-1)
+	case KEY_DOWN, KEY_CTRL_N:
+		e.MoveHistory(1)
+	case KEY_TAB:
+		e.CompleteWord()
+	default:
+		if key >= ' ' && key != KEY_BACKSPACE {
+			e.Insert(rune(key))
+		}
+	}
+	return "", false, nil
+}
+
+// ReadKey reads one key press, decoding the escape sequences terminals send
+// for arrows and other special keys.
+func (e *Editor) ReadKey() (Key, error) {
+	r, _, err := e.Reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if Key(r) != KEY_ESCAPE {
+		return Key(r), nil
+	}
+
+	r, _, err = e.Reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	switch r {
+	case '[':
+		return e.ReadSequence()
+	case 'O':
+		r, _, err = e.Reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		return FinalKey("", r), nil
+	case 'b':
+		return KEY_WORD_LEFT, nil
+	case 'f':
+		return KEY_WORD_RIGHT, nil
+	default:
+		return KEY_UNKNOWN, nil
+	}
+}
+
+// ReadSequence reads the rest of a control sequence after "ESC [": numeric
+// parameters separated by ';' and then a final character.
+func (e *Editor) ReadSequence() (Key, error) {
+	var params strings.Builder
+	for {
+		r, _, err := e.Reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if (r >= '0' && r <= '9') || r == ';' {
+			params.WriteRune(r)
+			continue
+		}
+		return FinalKey(params.String(), r), nil
+	}
+}
+
+func FinalKey(params string, final rune) Key {
+	// Modifiers arrive as a second parameter, "1;5" being Ctrl.
+	modified := strings.HasPrefix(params, "1;")
+
+	switch final {
+	case 'A':
+		return KEY_UP
+	case 'B':
+		return KEY_DOWN
+	case 'C':
+		if modified {
+			return KEY_WORD_RIGHT
+		}
+		return KEY_RIGHT
+	case 'D':
+		if modified {
+			return KEY_WORD_LEFT
+		}
+		return KEY_LEFT
+	case 'H':
+		return KEY_HOME
+	case 'F':
+		return KEY_END
+	case '~':
+		switch params {
+		case "1", "7":
+			return KEY_HOME
+		case "4", "8":
+			return KEY_END
+		case "3":
+			return KEY_DELETE
+		}
+	}
+	return KEY_UNKNOWN
+}
+
+func (e *Editor) Write(s string) {
+	io.WriteString(e.Writer, s)
+}
+
+// Refresh redraws the prompt and line and puts the terminal's cursor back
+// where the line's cursor is.
+func (e *Editor) Refresh() {
+	var buffer bytes.Buffer
+	buffer.WriteString("\r")
+	buffer.WriteString(e.Prompt)
+	buffer.WriteString(string(e.Line))
+	buffer.WriteString("\x1b[K\r")
+
+	column := utf8.RuneCountInString(e.Prompt) + e.Cursor
+	if column > 0 {
+		fmt.Fprintf(&buffer, "\x1b[%dC", column)
+	}
+	e.Writer.Write(buffer.Bytes())
+}
+
+func (e *Editor) Insert(runes ...rune) {
+	line := make([]rune, 0, len(e.Line)+len(runes))
+	line = append(line, e.Line[:e.Cursor]...)
+	line = append(line, runes...)
+	line = append(line, e.Line[e.Cursor:]...)
+	e.Line = line
+	e.Cursor += len(runes)
+	e.Refresh()
+}
+
+// DeleteRange removes the runes from start up to end, clamped to the line.
+func (e *Editor) DeleteRange(start int, end int) {
+	start = max(start, 0)
+	end = min(end, len(e.Line))
+	if start >= end {
+		return
+	}
+
+	e.Line = append(e.Line[:start], e.Line[end:]...)
+	if e.Cursor > end {
+		e.Cursor -= end - start
+	} else if e.Cursor > start {
+		e.Cursor = start
+	}
+	e.Refresh()
+}
+
+func (e *Editor) MoveTo(cursor int) {
+	if cursor < 0 || cursor > len(e.Line) || cursor == e.Cursor {
+		return
+	}
+	e.Cursor = cursor
+	e.Refresh()
+}
+
+func (e *Editor) SetLine(line string) {
+	e.Line = []rune(line)
+	e.Cursor = len(e.Line)
+	e.Refresh()
+}
+
+func IsWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// WordStart is the start of the word before the cursor, skipping any spaces
+// or punctuation in between.
+func (e *Editor) WordStart() int {
+	cursor := e.Cursor
+	for cursor > 0 && !IsWordRune(e.Line[cursor-1]) {
+		cursor--
+	}
+	for cursor > 0 && IsWordRune(e.Line[cursor-1]) {
+		cursor--
+	}
+	return cursor
+}
+
+// WordEnd is the end of the word after the cursor.
+func (e *Editor) WordEnd() int {
+	cursor := e.Cursor
+	for cursor < len(e.Line) && !IsWordRune(e.Line[cursor]) {
+		cursor++
+	}
+	for cursor < len(e.Line) && IsWordRune(e.Line[cursor]) {
+		cursor++
+	}
+	return cursor
+}
+
+// MoveHistory replaces the line with an older (-1) or newer (1) history
+// entry. The line being typed is kept, to come back to after the newest.
+func (e *Editor) MoveHistory(delta int) {
+	index := e.HistoryIndex + delta
+	if index < 0 || index > len(e.History) {
+		return
+	}
+
+	if e.HistoryIndex == len(e.History) {
+		e.Pending = string(e.Line)
+	}
+
+	e.HistoryIndex = index
+	if index == len(e.History) {
+		e.SetLine(e.Pending)
+	} else {
+		e.SetLine(e.History[index])
+	}
+}
+
+// CompleteWord completes the word before the cursor. A single match is
+// inserted whole; several matches are completed as far as they agree, and
+// listed when they already agree no further than what was typed.
+func (e *Editor) CompleteWord() {
+	if e.Complete == nil {
+		return
+	}
+
+	start := e.Cursor
+	for start > 0 && IsWordRune(e.Line[start-1]) {
+		start--
+	}
+	prefix := string(e.Line[start:e.Cursor])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.Complete(prefix)
+	if len(candidates) == 0 {
+		e.Write("\a")
+		return
+	}
+
+	common := CommonPrefix(candidates)
+	if len(common) > len(prefix) {
+		e.Insert([]rune(common[len(prefix):])...)
+		return
+	}
+
+	if len(candidates) > 1 {
+		e.Write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+		e.Refresh()
+	}
+}
+
+func CommonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// Search runs a reverse incremental search of the history, started by
+// Ctrl-R. Typing narrows the search, Ctrl-R again finds an older match and
+// Ctrl-G gives up. Any other key takes the match as the line and is returned
+// to be handled as usual, so Enter runs it straight away.
+func (e *Editor) Search() (Key, error) {
+	original := string(e.Line)
+	query := make([]rune, 0)
+	index := len(e.History)
+	match := ""
+	failing := false
+
+	find := func(from int) {
+		for i := min(from, len(e.History)-1); i >= 0; i-- {
+			if strings.Contains(e.History[i], string(query)) {
+				index, match, failing = i, e.History[i], false
+				return
+			}
+		}
+		failing = true
+	}
+
+	for {
+		label := "reverse-i-search"
+		if failing {
+			label = "failing " + label
+		}
+		e.Write(fmt.Sprintf("\r(%s)`%s': %s\x1b[K", label, string(query), match))
+
+		key, err := e.ReadKey()
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case key == KEY_CTRL_R:
+			find(index - 1)
+		case key == KEY_BACKSPACE || key == KEY_CTRL_H:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(index)
+			}
+		case key == KEY_CTRL_G:
+			e.SetLine(original)
+			return 0, nil
+		case key >= ' ':
+			query = append(query, rune(key))
+			find(index)
+		default:
+			if match != "" {
+				e.HistoryIndex = index
+				e.SetLine(match)
+			} else {
+				e.Refresh()
+			}
+			return key, nil
+		}
+	}
+}
+
+// AddHistory records an entered line, skipping blank lines and repeats of the
+// previous one, and appends it to the history file if there is one.
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(e.History) > 0 && e.History[len(e.History)-1] == line {
+		return
+	}
+
+	e.History = append(e.History, line)
+	if len(e.History) > HISTORY_MAX {
+		e.History = e.History[len(e.History)-HISTORY_MAX:]
+	}
+
+	if e.HistoryFile == "" {
+		return
+	}
+
+	file, err := os.OpenFile(e.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// LoadHistory reads the history saved at path and appends to it from then
+// on. A missing file is not an error; it is created with the first line. A
+// file that has grown past HISTORY_MAX lines is trimmed.
+func (e *Editor) LoadHistory(path string) error {
+	e.HistoryFile = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	history := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			history = append(history, line)
+		}
+	}
+
+	if len(history) <= HISTORY_MAX {
+		e.History = history
+		return nil
+	}
+
+	e.History = history[len(history)-HISTORY_MAX:]
+	return os.WriteFile(path, []byte(strings.Join(e.History, "\n")+"\n"), 0o600)
+}
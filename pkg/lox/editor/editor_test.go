@@ -0,0 +1,196 @@
+package editor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func terminal(input string) *Editor {
+	editor := NewEditor(strings.NewReader(input), io.Discard)
+	editor.Terminal = true
+	return editor
+}
+
+func readLine(t *testing.T, editor *Editor) string {
+	line, err := editor.ReadLine("> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
+
+func TestEditor_ReadLine_Plain(t *testing.T) {
+	editor := NewEditor(strings.NewReader("print 1;\r\nprint 2;"), io.Discard)
+
+	if line := readLine(t, editor); line != "print 1;" {
+		t.Fatalf("unexpected line %q", line)
+	}
+	if line := readLine(t, editor); line != "print 2;" {
+		t.Fatalf("unexpected line %q", line)
+	}
+
+	_, err := editor.ReadLine("> ")
+	if err != io.EOF {
+		t.Fatal("expected EOF")
+	}
+}
+
+func TestEditor_Edit_CursorMovement(t *testing.T) {
+	inputs := map[string]string{
+		"ac\x1b[Db\r":               "abc",
+		"bc\x01a\x05d\r":            "abcd",
+		"abc\x1b[H\x1b[3~\r":        "bc",
+		"abc\x02\x02\x7f\r":         "bc",
+		"one two\x1bbX\x1b[1;5CY\r": "one XtwoY",
+		"one two\x17\r":             "one ",
+		"one two\x01\x06\x0b\r":     "o",
+		"one two\x1b[D\x15\r":       "o",
+	}
+
+	for input, expected := range inputs {
+		line := readLine(t, terminal(input))
+		if line != expected {
+			t.Fatalf("expected %q from %q, got %q", expected, input, line)
+		}
+	}
+}
+
+func TestEditor_Edit_ControlKeys(t *testing.T) {
+	_, err := terminal("abc\x03").ReadLine("> ")
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatal("expected Ctrl-C to interrupt")
+	}
+
+	_, err = terminal("\x04").ReadLine("> ")
+	if err != io.EOF {
+		t.Fatal("expected Ctrl-D on an empty line to be EOF")
+	}
+
+	line := readLine(t, terminal("ab\x01\x04\r"))
+	if line != "b" {
+		t.Fatalf("expected Ctrl-D to delete, got %q", line)
+	}
+}
+
+func TestEditor_Edit_History(t *testing.T) {
+	editor := terminal("first\rsecond\r\x1b[A\x1b[A\r\x10\x0e\x0e\r")
+
+	readLine(t, editor)
+	readLine(t, editor)
+
+	if line := readLine(t, editor); line != "first" {
+		t.Fatalf("expected the older entry, got %q", line)
+	}
+
+	if line := readLine(t, editor); line != "" {
+		t.Fatalf("expected to come back to the empty line, got %q", line)
+	}
+
+	if len(editor.History) != 3 {
+		t.Fatalf("expected repeats and blank lines to be skipped, got %q", editor.History)
+	}
+}
+
+func TestEditor_Edit_Search(t *testing.T) {
+	editor := terminal("\x12co\x12\r")
+	editor.History = []string{"var counter = 0;", "print 1;", "count();"}
+
+	if line := readLine(t, editor); line != "var counter = 0;" {
+		t.Fatalf("expected the second match, got %q", line)
+	}
+
+	editor = terminal("typed\x12zz\x07\r")
+	editor.History = []string{"print 1;"}
+
+	if line := readLine(t, editor); line != "typed" {
+		t.Fatalf("expected Ctrl-G to restore the line, got %q", line)
+	}
+
+	editor = terminal("\x12pr\x1b[D\x1b[Dx\r")
+	editor.History = []string{"print 1;"}
+
+	if line := readLine(t, editor); line != "print x1;" {
+		t.Fatalf("expected to keep editing the match, got %q", line)
+	}
+}
+
+func TestEditor_Edit_Complete(t *testing.T) {
+	words := []string{"class", "clock", "counter", "print"}
+	complete := func(prefix string) []string {
+		matches := make([]string, 0)
+		for _, word := range words {
+			if strings.HasPrefix(word, prefix) {
+				matches = append(matches, word)
+			}
+		}
+		return matches
+	}
+
+	inputs := map[string]string{
+		"pr\t 1;\r":   "print 1;",
+		"cou\t\r":     "counter",
+		"c\tlo\t\r":   "clock",
+		"x = cl\t\r":  "x = cl",
+		"zz\t\r":      "zz",
+		"print(c\t\r": "print(c",
+	}
+
+	for input, expected := range inputs {
+		editor := terminal(input)
+		editor.Complete = complete
+		line := readLine(t, editor)
+		if line != expected {
+			t.Fatalf("expected %q from %q, got %q", expected, input, line)
+		}
+	}
+}
+
+func TestEditor_LoadHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	editor := terminal("print 1;\rprint 2;\r")
+	err := editor.LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, editor)
+	readLine(t, editor)
+
+	editor = terminal("\x1b[A\x1b[A\r")
+	err = editor.LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line := readLine(t, editor); line != "print 1;" {
+		t.Fatalf("expected history from the last session, got %q", line)
+	}
+}
+
+func TestEditor_LoadHistory_Trim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	lines := make([]string, 0)
+	for i := 0; i < HISTORY_MAX+10; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+
+	editor := terminal("")
+	err := editor.LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(editor.History) != HISTORY_MAX || editor.History[0] != lines[10] {
+		t.Fatal("expected the oldest lines to be dropped")
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != HISTORY_MAX {
+		t.Fatal("expected the file to be trimmed")
+	}
+}
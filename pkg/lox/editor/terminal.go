@@ -0,0 +1,60 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package editor
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// State is a terminal's settings, saved so that they can be restored after
+// raw mode.
+type State struct {
+	termios syscall.Termios
+}
+
+func IsTerminal(fd int) bool {
+	_, err := GetState(fd)
+	return err == nil
+}
+
+func GetState(fd int) (*State, error) {
+	state := &State{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlGetTermios, uintptr(unsafe.Pointer(&state.termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return state, nil
+}
+
+func SetState(fd int, state *State) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(&state.termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// MakeRaw puts the terminal into raw mode, where every key is read as it is
+// pressed and nothing is echoed, and returns the state to restore afterwards.
+func MakeRaw(fd int) (*State, error) {
+	old, err := GetState(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.termios.Oflag &^= syscall.OPOST
+	raw.termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.termios.Cflag |= syscall.CS8
+	raw.termios.Cc[syscall.VMIN] = 1
+	raw.termios.Cc[syscall.VTIME] = 0
+
+	err = SetState(fd, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
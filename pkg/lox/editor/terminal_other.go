@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package editor
+
+import "errors"
+
+// State is empty on platforms without termios, where input is always read a
+// line at a time.
+type State struct{}
+
+func IsTerminal(fd int) bool {
+	return false
+}
+
+func MakeRaw(fd int) (*State, error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
+
+func SetState(fd int, state *State) error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"io"
+	"testing"
+)
+
+func benchmark(b *testing.B, source string) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		interpreter := NewInterpreter()
+		interpreter.Writer = io.Discard
+		interpreter.Resolve(locals)
+		err = interpreter.Interpret(statements)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFib runs the README's Fibonacci example.
+func BenchmarkFib(b *testing.B) {
+	benchmark(b, `
+fun fib(n) {
+  if (n <= 1) return n;
+  return fib(n - 2) + fib(n - 1);
+}
+
+for (var i = 0; i < 20; i = i + 1) {
+  print fib(i);
+}
+`)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmark(b, `
+var sum = 0;
+for (var i = 0; i < 10000; i = i + 1) {
+  if (i % 2 == 0) sum = sum + i;
+}
+`)
+}
+
+func BenchmarkMethods(b *testing.B) {
+	benchmark(b, `
+class Counter {
+  init() { this.count = 0; }
+  increment() {
+    this.count = this.count + 1;
+    return this;
+  }
+}
+
+var counter = Counter();
+for (var i = 0; i < 10000; i = i + 1) {
+  counter.increment();
+}
+`)
+}
+
+func BenchmarkTailCalls(b *testing.B) {
+	benchmark(b, `
+fun countdown(n) {
+  if (n == 0) return n;
+  return countdown(n - 1);
+}
+countdown(10000);
+`)
+}
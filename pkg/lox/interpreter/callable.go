@@ -2,39 +2,144 @@ package interpreter
 
 import (
 	"golox/pkg/lox/ast"
+	"golox/pkg/lox/token"
 )
 
+// Callable is anything a script can call. Paren is the call's closing
+// parenthesis, where errors raised by the call itself are reported.
 type Callable interface {
-	Call(i Interpreter, arguments []interface{}) interface{}
+	Arity() int
+	Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{}
 }
 
 type LoxFunction struct {
-	Declaration *ast.Function
+	Declaration   *ast.Function
+	Closure       *Environment
+	IsInitializer bool
 }
 
-func (f *LoxFunction) Call(i Interpreter, arguments []interface{}) (returnValue interface{}) {
-	environment := NewEnvironment(i.Globals)
-	for i, _ := range f.Declaration.Params {
-		environment.Define(f.Declaration.Params[i].Lexeme, arguments[i])
+func (f *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
+	// Bound methods are made on every method call and usually dropped straight
+	// away, so the environment holding "this" isn't charged to the heap.
+	environment := &Environment{
+		Values:    map[string]interface{}{"this": instance},
+		Enclosing: f.Closure,
+		Heap:      f.Closure.Heap,
+	}
+	return &LoxFunction{
+		Declaration:   f.Declaration,
+		Closure:       environment,
+		IsInitializer: f.IsInitializer,
 	}
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.Declaration.Params)
+}
 
-	defer func() {
-		err := recover()
+func (f *LoxFunction) String() string {
+	return "<fn " + f.Declaration.Name.Lexeme + ">"
+}
 
-		if err == nil {
-			return
+// Call runs the function. A call in tail position, 'return g(x);', hands g
+// and its arguments back here instead of calling g from inside f's frame, so
+// tail-recursive functions run in constant Go stack.
+func (f *LoxFunction) Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	if i.Depth == MAX_DEPTH {
+		panic(NewRuntimeError(paren, "stack overflow"))
+	}
+	i.Depth++
+	defer func() { i.Depth-- }()
+
+	function, replaced := f, false
+	for {
+		result, tail := function.Execute(i, arguments, replaced)
+		if tail == nil {
+			return result
 		}
 
-		v, ok := err.(ReturnValue)
+		next, ok := tail.Callee.(*LoxFunction)
 		if !ok {
-			panic(err)
+			return tail.Callee.Call(i, tail.Paren, tail.Arguments)
 		}
+		function, arguments, replaced = next, tail.Arguments, true
+	}
+}
+
+// Execute runs the body once, returning either its result or the tail call
+// it ended with. Replaced says the body runs in place of a function that tail
+// called it.
+func (f *LoxFunction) Execute(i *Interpreter, arguments []interface{}, replaced bool) (interface{}, *TailCall) {
+	if i.Hook != nil {
+		i.Hook.Enter(i, f, replaced)
+		defer i.Hook.Leave(i, f)
+	}
+
+	environment := NewEnvironment(f.Closure)
+	for i, _ := range f.Declaration.Params {
+		environment.Define(f.Declaration.Params[i].Lexeme, arguments[i])
+	}
+
+	completion := i.ExecuteBlock(f.Declaration.Body, environment)
+
+	// An error completion has to cross the call expression that got us here,
+	// and expressions raise errors by panicking.
+	if completion.Type == ERROR {
+		panic(completion.Error)
+	}
+
+	if f.IsInitializer {
+		return f.Closure.Values["this"], nil
+	}
+
+	return completion.Value, completion.Tail
+}
 
-		returnValue = v.Value
-		return
-	}()
+// TailCall is a call made by a return statement, still to be run by the
+// caller's LoxFunction.Call loop.
+type TailCall struct {
+	Callee    Callable
+	Paren     token.Token
+	Arguments []interface{}
+}
 
-	i.ExecuteBlock(f.Declaration.Body, environment)
+type LoxClass struct {
+	Name       string
+	Superclass *LoxClass
+	Methods    map[string]*LoxFunction
+}
 
+func (c *LoxClass) FindMethod(name string) *LoxFunction {
+	method, ok := c.Methods[name]
+	if ok {
+		return method
+	}
+	if c.Superclass != nil {
+		return c.Superclass.FindMethod(name)
+	}
 	return nil
 }
+
+// Arity is the arity of the class's initializer, or zero if it has none.
+func (c *LoxClass) Arity() int {
+	initializer := c.FindMethod("init")
+	if initializer == nil {
+		return 0
+	}
+	return initializer.Arity()
+}
+
+func (c *LoxClass) Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	instance := NewLoxInstance(c, i.Heap)
+
+	initializer := c.FindMethod("init")
+	if initializer != nil {
+		initializer.Bind(instance).Call(i, paren, arguments)
+	}
+
+	return instance
+}
+
+func (c *LoxClass) String() string {
+	return c.Name
+}
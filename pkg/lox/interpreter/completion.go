@@ -0,0 +1,42 @@
+package interpreter
+
+type CompletionType int
+
+const (
+	NORMAL CompletionType = iota
+	RETURN
+	BREAK
+	CONTINUE
+	ERROR
+)
+
+// Completion is how a statement finished. Anything other than NORMAL is
+// handed back up through enclosing blocks, ifs and loops until something
+// consumes it: a loop for BREAK and CONTINUE, a function call for RETURN, and
+// Interpret for ERROR.
+//
+// Runtime errors raised while evaluating an expression still unwind by
+// panicking with a *RuntimeError, since expressions produce values rather
+// than completions. Interpret turns them into an ERROR completion.
+type Completion struct {
+	Type  CompletionType
+	Value interface{}
+	Tail  *TailCall
+	Error *RuntimeError
+}
+
+// Completions without a value are shared, so that the common case doesn't
+// allocate.
+var (
+	Normal   = &Completion{Type: NORMAL}
+	Break    = &Completion{Type: BREAK}
+	Continue = &Completion{Type: CONTINUE}
+)
+
+func Return(value interface{}) *Completion {
+	return &Completion{Type: RETURN, Value: value}
+}
+
+func Fail(err *RuntimeError) *Completion {
+	return &Completion{Type: ERROR, Error: err}
+}
@@ -5,12 +5,22 @@ import "golox/pkg/lox/token"
 type Environment struct {
 	Values    map[string]interface{}
 	Enclosing *Environment
+	Heap      *Heap
+	Size      int
+	Captured  bool
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
+	var heap *Heap
+	if enclosing != nil {
+		heap = enclosing.Heap
+	}
+	heap.Allocate(ENVIRONMENT_SIZE)
 	return &Environment{
 		Values:    make(map[string]interface{}),
 		Enclosing: enclosing,
+		Heap:      heap,
+		Size:      ENVIRONMENT_SIZE,
 	}
 }
 
@@ -18,16 +28,33 @@ func (e *Environment) Assign(name token.Token, value interface{}) {
 	_, ok := e.Values[name.Lexeme]
 
 	if ok {
-		e.Values[name.Lexeme] = value
+		e.Set(name.Lexeme, value)
 	} else if e.Enclosing != nil {
 		e.Enclosing.Assign(name, value)
 	} else {
-		panic("undefined variable")
+		panic(NewRuntimeError(name, "undefined variable '"+name.Lexeme+"'"))
 	}
 }
 
+func (e *Environment) AssignAt(distance int, name token.Token, value interface{}) {
+	e.Ancestor(distance).Set(name.Lexeme, value)
+}
+
 func (e *Environment) Define(name string, value interface{}) {
+	e.Set(name, value)
+}
+
+// Set stores value under name in this environment and charges the change in
+// size to the heap.
+func (e *Environment) Set(name string, value interface{}) {
+	size := EntrySize(name, value)
+	old, ok := e.Values[name]
+	if ok {
+		size -= EntrySize(name, old)
+	}
 	e.Values[name] = value
+	e.Size += size
+	e.Heap.Allocate(size)
 }
 
 func (e *Environment) Get(name token.Token) interface{} {
@@ -37,6 +64,52 @@ func (e *Environment) Get(name token.Token) interface{} {
 	} else if e.Enclosing != nil {
 		return e.Enclosing.Get(name)
 	} else {
-		panic("undefined variable")
+		panic(NewRuntimeError(name, "undefined variable '"+name.Lexeme+"'"))
+	}
+}
+
+func (e *Environment) GetAt(distance int, name string) interface{} {
+	return e.Ancestor(distance).Values[name]
+}
+
+// Names lists every name visible from this environment, innermost first and
+// without the ones shadowed by an inner scope.
+func (e *Environment) Names() []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for environment := e; environment != nil; environment = environment.Enclosing {
+		for name := range environment.Values {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func (e *Environment) Ancestor(distance int) *Environment {
+	environment := e
+	for i := 0; i < distance; i++ {
+		environment = environment.Enclosing
+	}
+	return environment
+}
+
+// Capture marks this environment and everything enclosing it as reachable
+// from a closure, so they stay charged after their scope exits.
+func (e *Environment) Capture() {
+	for environment := e; environment != nil && !environment.Captured; environment = environment.Enclosing {
+		environment.Captured = true
+	}
+}
+
+// Release returns the environment's bytes to the heap once its scope has
+// exited, unless a closure still refers to it.
+func (e *Environment) Release() {
+	if e.Captured {
+		return
 	}
+	e.Heap.Free(e.Size)
+	e.Size = 0
 }
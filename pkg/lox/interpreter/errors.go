@@ -0,0 +1,34 @@
+package interpreter
+
+import (
+	"fmt"
+	"golox/pkg/lox/token"
+)
+
+type RuntimeError struct {
+	Token   token.Token
+	Line    int
+	Column  int
+	Message string
+}
+
+func NewRuntimeError(t token.Token, message string) *RuntimeError {
+	return &RuntimeError{
+		Token:   t,
+		Line:    t.Line,
+		Column:  t.Column,
+		Message: message,
+	}
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("[line %d:%d] %s", e.Line, e.Column, e.Message)
+}
+
+func (e *RuntimeError) Span() token.Span {
+	return e.Token.Span()
+}
+
+func (e *RuntimeError) Reason() string {
+	return e.Message
+}
@@ -0,0 +1,163 @@
+package interpreter
+
+import (
+	"fmt"
+	"golox/pkg/lox/token"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Approximate sizes, in bytes, of the Go structures backing Lox values on a
+// 64-bit host.
+const (
+	ENVIRONMENT_SIZE = 64
+	ENTRY_SIZE       = 32
+	NUMBER_SIZE      = 8
+	STRING_SIZE      = 16
+	FUNCTION_SIZE    = 48
+	CLASS_SIZE       = 64
+	INSTANCE_SIZE    = 64
+	REFERENCE_SIZE   = 8
+)
+
+// Heap keeps an estimate of the bytes held by a running Lox program.
+//
+// Environments are charged for their variables and returned to the heap when
+// their scope exits, unless a closure captured them. Instances are charged for
+// themselves and their fields through an Allocation, which Go's garbage
+// collector returns once the program can no longer reach the instance. Before
+// failing a check, the heap collects garbage so that instances the program has
+// dropped don't count against the limit.
+type Heap struct {
+	Current int
+	Peak    int
+	Limit   int
+
+	// released holds bytes returned by finalizers, which run on their own
+	// goroutine, until the interpreter's goroutine takes them off Current.
+	released atomic.Int64
+}
+
+// NewHeap returns a heap that allows at most limit bytes. A limit of zero
+// disables the check but keeps the accounting.
+func NewHeap(limit int) *Heap {
+	return &Heap{
+		Limit: limit,
+	}
+}
+
+func (h *Heap) Allocate(bytes int) {
+	if h == nil {
+		return
+	}
+	h.Reclaim()
+	h.Current += bytes
+	if h.Current > h.Peak {
+		h.Peak = h.Current
+	}
+}
+
+func (h *Heap) Free(bytes int) {
+	if h == nil {
+		return
+	}
+	h.Current -= bytes
+}
+
+// Check raises a runtime error at t if allocating another bytes would take
+// the heap over its limit, even once unreachable instances are collected.
+func (h *Heap) Check(t token.Token, bytes int) {
+	if h == nil || h.Limit <= 0 {
+		return
+	}
+	h.Reclaim()
+	if h.Current+bytes <= h.Limit {
+		return
+	}
+
+	h.Collect()
+	if h.Current+bytes > h.Limit {
+		panic(NewRuntimeError(t, fmt.Sprintf("heap limit of %d bytes exceeded", h.Limit)))
+	}
+}
+
+// Reclaim takes the bytes finalizers have released off Current.
+func (h *Heap) Reclaim() {
+	h.Current -= int(h.released.Swap(0))
+}
+
+// Collect runs the garbage collector and waits for the finalizers of the
+// instances it found to release their bytes. Finalizers run one at a time on
+// a single goroutine, so once a sentinel queued by a second collection has
+// run, everything queued by the first has too.
+func (h *Heap) Collect() {
+	for round := 0; round < 2; round++ {
+		done := make(chan struct{})
+		sentinel := &struct{ next *int }{}
+		runtime.SetFinalizer(sentinel, func(*struct{ next *int }) { close(done) })
+		sentinel = nil
+
+		runtime.GC()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}
+	h.Reclaim()
+}
+
+// Allocation is the bytes charged for an object the program can drop at any
+// time. It's owned by the object but doesn't refer back to it, so a cycle of
+// objects can still be collected, after which the allocation's finalizer
+// returns its bytes to the heap.
+type Allocation struct {
+	Heap *Heap
+	size atomic.Int64
+}
+
+func (h *Heap) Track(bytes int) *Allocation {
+	if h == nil {
+		return nil
+	}
+	allocation := &Allocation{Heap: h}
+	allocation.Grow(bytes)
+	runtime.SetFinalizer(allocation, func(a *Allocation) {
+		a.Heap.released.Add(a.size.Load())
+	})
+	return allocation
+}
+
+// Grow charges a change in the object's size, which may be negative.
+func (a *Allocation) Grow(bytes int) {
+	if a == nil {
+		return
+	}
+	a.size.Add(int64(bytes))
+	a.Heap.Allocate(bytes)
+}
+
+// SizeOf estimates the bytes held by a single reference to value. Instances
+// are charged for their fields separately, so only the reference counts here.
+func SizeOf(value interface{}) int {
+	switch value.(type) {
+	case nil, Boolean:
+		return 0
+	case Integer, Float:
+		return NUMBER_SIZE
+	case BigInteger:
+		return NUMBER_SIZE * (len(value.(BigInteger).Bits()) + 4)
+	case String:
+		return STRING_SIZE + len(value.(String))
+	case *LoxFunction, *NativeFunction:
+		return FUNCTION_SIZE
+	case *LoxClass:
+		return CLASS_SIZE
+	default:
+		return REFERENCE_SIZE
+	}
+}
+
+func EntrySize(name string, value interface{}) int {
+	return ENTRY_SIZE + len(name) + SizeOf(value)
+}
@@ -0,0 +1,47 @@
+package interpreter
+
+import "golox/pkg/lox/token"
+
+// LoxInstance is charged to the heap for itself and its fields until it is
+// garbage collected. See Heap.
+type LoxInstance struct {
+	Class      *LoxClass
+	Fields     map[string]interface{}
+	Allocation *Allocation
+}
+
+func NewLoxInstance(class *LoxClass, heap *Heap) *LoxInstance {
+	return &LoxInstance{
+		Class:      class,
+		Fields:     make(map[string]interface{}),
+		Allocation: heap.Track(INSTANCE_SIZE),
+	}
+}
+
+func (o *LoxInstance) Get(name token.Token) interface{} {
+	value, ok := o.Fields[name.Lexeme]
+	if ok {
+		return value
+	}
+
+	method := o.Class.FindMethod(name.Lexeme)
+	if method != nil {
+		return method.Bind(o)
+	}
+
+	panic(NewRuntimeError(name, "undefined property '"+name.Lexeme+"'"))
+}
+
+func (o *LoxInstance) Set(name token.Token, value interface{}) {
+	size := EntrySize(name.Lexeme, value)
+	old, ok := o.Fields[name.Lexeme]
+	if ok {
+		size -= EntrySize(name.Lexeme, old)
+	}
+	o.Fields[name.Lexeme] = value
+	o.Allocation.Grow(size)
+}
+
+func (o *LoxInstance) String() string {
+	return o.Class.Name + " instance"
+}
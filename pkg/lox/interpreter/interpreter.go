@@ -4,54 +4,201 @@ import (
 	"fmt"
 	"golox/pkg/lox/ast"
 	"golox/pkg/lox/token"
+	"io"
+	"os"
 )
 
+// MAX_DEPTH is how many calls can be in progress at once before a script is
+// stopped with a stack overflow, the same as the VM allows.
+const MAX_DEPTH = 1<<16 - 1
+
 type Interpreter struct {
 	Env     *Environment
 	Globals *Environment
+	Locals  map[ast.Expression]int
+	Writer  io.Writer
+	Heap    *Heap
+	Hook    Hook
+
+	// Depth counts the calls in progress. A chain of tail calls counts once.
+	Depth int
+}
+
+// Hook lets a debugger follow execution. Statement is called before each
+// statement runs, with i.Env the environment it runs in. Enter and Leave
+// bracket each run of a function's body, while i.Env is still the caller's.
+// Enter is told when the function was tail called, having taken over the
+// frame of the function that called it, which has already left.
+type Hook interface {
+	Statement(i *Interpreter, stmt ast.Statement)
+	Enter(i *Interpreter, function *LoxFunction, tail bool)
+	Leave(i *Interpreter, function *LoxFunction)
 }
 
 func NewInterpreter() *Interpreter {
+	heap := NewHeap(0)
 	env := NewEnvironment(nil)
-	return &Interpreter{
+	env.Heap = heap
+	heap.Allocate(env.Size)
+	interpreter := &Interpreter{
 		Env:     env,
 		Globals: env,
+		Locals:  make(map[ast.Expression]int),
+		Writer:  os.Stdout,
+		Heap:    heap,
+	}
+	for _, native := range Builtins {
+		interpreter.Globals.Define(native.Name, native)
+	}
+	return interpreter
+}
+
+// DefineNative exposes a Go function to scripts as a global. Calls to it are
+// checked to pass exactly arity arguments.
+func (i *Interpreter) DefineNative(name string, arity int, function func(arguments []interface{}) (interface{}, error)) {
+	i.Globals.Define(name, &NativeFunction{
+		Name:     name,
+		ArgCount: arity,
+		Function: function,
+	})
+}
+
+// Interpret runs statements, returning the runtime error that stopped them, if
+// any. The statements should have been passed through the resolver and its
+// result given to Resolve first, or local variables won't be found.
+func (i *Interpreter) Interpret(statements []ast.Statement) error {
+	completion := i.ExecuteStatements(statements)
+	if completion.Type == ERROR {
+		return completion.Error
+	}
+	return nil
+}
+
+// ExecuteStatements runs top-level statements, turning a runtime error raised
+// by an expression into an ERROR completion.
+func (i *Interpreter) ExecuteStatements(statements []ast.Statement) (completion *Completion) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		runtimeError, ok := r.(*RuntimeError)
+		if !ok {
+			panic(r)
+		}
+
+		i.Env = i.Globals
+		completion = Fail(runtimeError)
+	}()
+
+	for _, statement := range statements {
+		completion := i.Execute(statement)
+		if completion.Type != NORMAL {
+			return completion
+		}
+	}
+
+	return Normal
+}
+
+func (i *Interpreter) Execute(stmt ast.Statement) *Completion {
+	if i.Hook != nil {
+		i.Hook.Statement(i, stmt)
 	}
+	return stmt.Accept(i).(*Completion)
 }
 
-func (i Interpreter) VisitAssignment(expr *ast.Assignment) interface{} {
+func (i *Interpreter) Resolve(locals map[ast.Expression]int) {
+	for expr, depth := range locals {
+		i.Locals[expr] = depth
+	}
+}
+
+func (i *Interpreter) LookUpVariable(name token.Token, expr ast.Expression) interface{} {
+	distance, ok := i.Locals[expr]
+	if ok {
+		return i.Env.GetAt(distance, name.Lexeme)
+	}
+	return i.Globals.Get(name)
+}
+
+func (i *Interpreter) VisitAssignment(expr *ast.Assignment) interface{} {
 	value := expr.Value.Accept(i)
-	i.Env.Assign(expr.Name, value)
+
+	distance, ok := i.Locals[expr]
+	if ok {
+		i.Env.AssignAt(distance, expr.Name, value)
+	} else {
+		i.Globals.Assign(expr.Name, value)
+	}
+	i.Heap.Check(expr.Name, 0)
+
 	return value
 }
 
-func (i Interpreter) VisitBinary(expr *ast.Binary) interface{} {
-	switch expr.Operation.Lexeme {
-	case "+":
-		return expr.Left.Accept(i).(float32) + expr.Right.Accept(i).(float32)
-	case "-":
-		return expr.Left.Accept(i).(float32) - expr.Right.Accept(i).(float32)
-	case "*":
-		return expr.Left.Accept(i).(float32) * expr.Right.Accept(i).(float32)
-	case "/":
-		return expr.Left.Accept(i).(float32) / expr.Right.Accept(i).(float32)
-	case "==":
-		return expr.Left.Accept(i) == expr.Right.Accept(i)
-	case "!=":
-		return expr.Left.Accept(i) != expr.Right.Accept(i)
-	case ">":
-		return expr.Left.Accept(i).(float32) > expr.Right.Accept(i).(float32)
-	case ">=":
-		return expr.Left.Accept(i).(float32) >= expr.Right.Accept(i).(float32)
-	case "<":
-		return expr.Left.Accept(i).(float32) < expr.Right.Accept(i).(float32)
-	case "<=":
-		return expr.Left.Accept(i).(float32) <= expr.Right.Accept(i).(float32)
+func (i *Interpreter) VisitBinary(expr *ast.Binary) interface{} {
+	left := expr.Left.Accept(i)
+	right := expr.Right.Accept(i)
+
+	switch expr.Operation.Type {
+	case token.EQUAL_EQUAL:
+		return IsEqual(left, right)
+	case token.BANG_EQUAL:
+		return !IsEqual(left, right)
+	case token.PLUS:
+		a, aok := left.(String)
+		b, bok := right.(String)
+		if aok && bok {
+			i.Heap.Check(expr.Operation, STRING_SIZE+len(a)+len(b))
+			return a + b
+		}
+
+		if !IsNumber(left) || !IsNumber(right) {
+			panic(NewRuntimeError(expr.Operation, "operands must be two numbers or two strings"))
+		}
+	}
+
+	CheckNumberOperands(expr.Operation, left, right)
+
+	switch expr.Operation.Type {
+	case token.PLUS:
+		return Add(left, right)
+	case token.MINUS:
+		return Subtract(left, right)
+	case token.STAR:
+		return Multiply(left, right)
+	case token.SLASH:
+		return Divide(expr.Operation, left, right)
+	case token.PERCENT:
+		return Modulo(expr.Operation, left, right)
+	}
+
+	result, ok := Compare(left, right)
+
+	switch expr.Operation.Type {
+	case token.GREATER:
+		return ok && result > 0
+	case token.GREATER_EQUAL:
+		return ok && result >= 0
+	case token.LESS:
+		return ok && result < 0
+	case token.LESS_EQUAL:
+		return ok && result <= 0
 	}
 	return nil
 }
 
-func (i Interpreter) VisitCall(expr *ast.Call) interface{} {
+func (i *Interpreter) VisitCall(expr *ast.Call) interface{} {
+	function, arguments := i.EvaluateCall(expr)
+	result := function.Call(i, expr.Paren, arguments)
+	i.Heap.Check(expr.Paren, 0)
+	return result
+}
+
+// EvaluateCall evaluates the callee and arguments of a call and checks that
+// the callee can be called with them, leaving only the call itself to be made.
+func (i *Interpreter) EvaluateCall(expr *ast.Call) (Callable, []interface{}) {
 	callee := expr.Callee.Accept(i)
 
 	arguments := make([]interface{}, 0)
@@ -59,24 +206,39 @@ func (i Interpreter) VisitCall(expr *ast.Call) interface{} {
 		arguments = append(arguments, argument.Accept(i))
 	}
 
-	function := callee.(Callable)
-	return function.Call(i, arguments)
+	function, ok := callee.(Callable)
+	if !ok {
+		panic(NewRuntimeError(expr.Paren, "can only call functions and classes"))
+	}
+
+	if len(arguments) != function.Arity() {
+		panic(NewRuntimeError(expr.Paren, fmt.Sprintf("expected %d arguments but got %d", function.Arity(), len(arguments))))
+	}
+
+	i.Heap.Check(expr.Paren, ENVIRONMENT_SIZE)
+	return function, arguments
 }
 
-func (i Interpreter) VisitGet(expr *ast.Get) interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *Interpreter) VisitGet(expr *ast.Get) interface{} {
+	object := expr.Object.Accept(i)
+
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(NewRuntimeError(expr.Name, "only instances have properties"))
+	}
+
+	return instance.Get(expr.Name)
 }
 
-func (i Interpreter) VisitGrouping(expr *ast.Grouping) interface{} {
+func (i *Interpreter) VisitGrouping(expr *ast.Grouping) interface{} {
 	return expr.Expr.Accept(i)
 }
 
-func (i Interpreter) VisitLiteral(expr *ast.Literal) interface{} {
+func (i *Interpreter) VisitLiteral(expr *ast.Literal) interface{} {
 	return expr.Value
 }
 
-func (i Interpreter) VisitLogical(expr *ast.Logical) interface{} {
+func (i *Interpreter) VisitLogical(expr *ast.Logical) interface{} {
 	left := expr.Left.Accept(i)
 
 	if expr.Operation.Type == token.OR {
@@ -92,100 +254,224 @@ func (i Interpreter) VisitLogical(expr *ast.Logical) interface{} {
 	return expr.Right.Accept(i)
 }
 
-func (i Interpreter) VisitSet(expr *ast.Set) interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *Interpreter) VisitSet(expr *ast.Set) interface{} {
+	object := expr.Object.Accept(i)
+
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(NewRuntimeError(expr.Name, "only instances have fields"))
+	}
+
+	value := expr.Value.Accept(i)
+	instance.Set(expr.Name, value)
+	i.Heap.Check(expr.Name, 0)
+	return value
 }
 
-func (i Interpreter) VisitSuper(expr *ast.Super) interface{} {
-	//TODO implement me
-	panic("implement me")
+// VisitSuper relies on the resolver to have found the scopes holding "super"
+// and "this". Without that, as when a host skips Resolve, it raises a runtime
+// error rather than crashing.
+func (i *Interpreter) VisitSuper(expr *ast.Super) interface{} {
+	// "this" is bound in a scope inside the one holding "super", so a resolved
+	// 'super' is never in the innermost scope.
+	distance, ok := i.Locals[expr]
+	if !ok || distance == 0 {
+		panic(NewRuntimeError(expr.Keyword, "can't use 'super' outside of a class with a superclass"))
+	}
+
+	superclass, isClass := i.Env.GetAt(distance, "super").(*LoxClass)
+	instance, isInstance := i.Env.GetAt(distance-1, "this").(*LoxInstance)
+	if !isClass || !isInstance {
+		panic(NewRuntimeError(expr.Keyword, "can't use 'super' outside of a class with a superclass"))
+	}
+
+	method := superclass.FindMethod(expr.Method.Lexeme)
+	if method == nil {
+		panic(NewRuntimeError(expr.Method, "undefined property '"+expr.Method.Lexeme+"'"))
+	}
+
+	return method.Bind(instance)
 }
 
-func (i Interpreter) VisitThis(expr *ast.This) interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *Interpreter) VisitThis(expr *ast.This) interface{} {
+	return i.LookUpVariable(expr.Keyword, expr)
 }
 
-func (i Interpreter) VisitUnary(expr *ast.Unary) interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *Interpreter) VisitUnary(expr *ast.Unary) interface{} {
+	operand := expr.Operand.Accept(i)
+
+	switch expr.Operation.Type {
+	case token.BANG:
+		return !IsTruthy(operand)
+	case token.MINUS:
+		CheckNumberOperand(expr.Operation, operand)
+		return Negate(operand)
+	}
+	return nil
 }
 
-func (i Interpreter) VisitVariable(expr *ast.Variable) interface{} {
-	return i.Env.Get(expr.Name)
+func (i *Interpreter) VisitVariable(expr *ast.Variable) interface{} {
+	return i.LookUpVariable(expr.Name, expr)
 }
 
-func (i Interpreter) VisitBlock(stmt *ast.Block) interface{} {
-	i.ExecuteBlock(stmt.Statements, NewEnvironment(i.Env))
-	return nil
+func (i *Interpreter) VisitBlock(stmt *ast.Block) interface{} {
+	return i.ExecuteBlock(stmt.Statements, NewEnvironment(i.Env))
 }
 
-func (i Interpreter) ExecuteBlock(statements []ast.Statement, environment *Environment) {
+func (i *Interpreter) ExecuteBlock(statements []ast.Statement, environment *Environment) *Completion {
 	previous := i.Env
+	defer func() {
+		i.Env = previous
+		environment.Release()
+	}()
 
 	i.Env = environment
 	for _, statement := range statements {
-		statement.Accept(i)
+		completion := i.Execute(statement)
+		if completion.Type != NORMAL {
+			return completion
+		}
+	}
+
+	return Normal
+}
+
+func (i *Interpreter) VisitBreak(stmt *ast.Break) interface{} {
+	return Break
+}
+
+func (i *Interpreter) VisitClass(stmt *ast.Class) interface{} {
+	var superclass *LoxClass
+	if stmt.Superclass != nil {
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			return Fail(NewRuntimeError(stmt.Superclass.Name, "a class can't inherit from itself"))
+		}
+
+		value, ok := stmt.Superclass.Accept(i).(*LoxClass)
+		if !ok {
+			return Fail(NewRuntimeError(stmt.Superclass.Name, "superclass must be a class"))
+		}
+		superclass = value
+	}
+
+	i.Env.Define(stmt.Name.Lexeme, nil)
+
+	closure := i.Env
+	if superclass != nil {
+		closure = NewEnvironment(closure)
+		closure.Define("super", superclass)
+	}
+	closure.Capture()
+
+	methods := make(map[string]*LoxFunction)
+	for index := range stmt.Methods {
+		method := &stmt.Methods[index]
+		methods[method.Name.Lexeme] = &LoxFunction{
+			Declaration:   method,
+			Closure:       closure,
+			IsInitializer: method.Name.Lexeme == "init",
+		}
 	}
 
-	i.Env = previous
+	class := &LoxClass{
+		Name:       stmt.Name.Lexeme,
+		Superclass: superclass,
+		Methods:    methods,
+	}
+
+	i.Env.Assign(stmt.Name, class)
+	i.Heap.Check(stmt.Name, 0)
+	return Normal
 }
 
-func (i Interpreter) VisitClass(stmt *ast.Class) interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *Interpreter) VisitContinue(stmt *ast.Continue) interface{} {
+	return Continue
 }
 
-func (i Interpreter) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
+func (i *Interpreter) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
 	stmt.Expr.Accept(i)
-	return nil
+	return Normal
 }
 
-func (i Interpreter) VisitFunction(stmt *ast.Function) interface{} {
-	function := &LoxFunction{stmt}
+func (i *Interpreter) VisitFunction(stmt *ast.Function) interface{} {
+	function := &LoxFunction{
+		Declaration: stmt,
+		Closure:     i.Env,
+	}
+	i.Env.Capture()
 	i.Env.Define(stmt.Name.Lexeme, function)
-	return nil
+	i.Heap.Check(stmt.Name, 0)
+	return Normal
 }
 
-func (i Interpreter) VisitIf(stmt *ast.If) interface{} {
+func (i *Interpreter) VisitIf(stmt *ast.If) interface{} {
 	if IsTruthy(stmt.Condition.Accept(i)) {
-		stmt.ThenBranch.Accept(i)
+		return i.Execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		stmt.ElseBranch.Accept(i)
+		return i.Execute(stmt.ElseBranch)
 	}
-	return nil
+	return Normal
 }
 
-func (i Interpreter) VisitPrint(stmt *ast.Print) interface{} {
-	fmt.Println(stmt.Expr.Accept(i))
-	return nil
+func (i *Interpreter) VisitPrint(stmt *ast.Print) interface{} {
+	fmt.Fprintln(i.Writer, Stringify(stmt.Expr.Accept(i)))
+	return Normal
 }
 
-type ReturnValue struct {
-	Value interface{}
-}
+// VisitReturn completes with the returned value, or, when the statement
+// returns the result of a call, with the call still to be made.
+func (i *Interpreter) VisitReturn(stmt *ast.Return) interface{} {
+	if call, ok := TailCallOf(stmt.Value); ok {
+		function, arguments := i.EvaluateCall(call)
+		return &Completion{
+			Type: RETURN,
+			Tail: &TailCall{Callee: function, Paren: call.Paren, Arguments: arguments},
+		}
+	}
 
-func (i Interpreter) VisitReturn(stmt *ast.Return) interface{} {
 	var value interface{}
 	if stmt.Value != nil {
 		value = stmt.Value.Accept(i)
 	}
-	panic(ReturnValue{value})
+	return Return(value)
+}
+
+// TailCallOf reports whether a returned expression is a call, looking through
+// any parentheses around it.
+func TailCallOf(expr ast.Expression) (*ast.Call, bool) {
+	switch expr := expr.(type) {
+	case ast.Call:
+		return &expr, true
+	case ast.Grouping:
+		return TailCallOf(expr.Expr)
+	default:
+		return nil, false
+	}
 }
 
-func (i Interpreter) VisitVar(stmt *ast.Var) interface{} {
+func (i *Interpreter) VisitVar(stmt *ast.Var) interface{} {
 	var value interface{}
 	if stmt.Initializer != nil {
 		value = stmt.Initializer.Accept(i)
 	}
 	i.Env.Define(stmt.Name.Lexeme, value)
-	return nil
+	i.Heap.Check(stmt.Name, 0)
+	return Normal
 }
 
-func (i Interpreter) VisitWhile(stmt *ast.While) interface{} {
+func (i *Interpreter) VisitWhile(stmt *ast.While) interface{} {
 	for IsTruthy(stmt.Condition.Accept(i)) {
-		stmt.Body.Accept(i)
+		completion := i.Execute(stmt.Body)
+		switch completion.Type {
+		case BREAK:
+			return Normal
+		case RETURN, ERROR:
+			return completion
+		}
+
+		if stmt.Increment != nil {
+			stmt.Increment.Accept(i)
+		}
 	}
-	return nil
+	return Normal
 }
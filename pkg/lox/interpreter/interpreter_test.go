@@ -0,0 +1,522 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"golox/pkg/lox/token"
+	"runtime/debug"
+	"testing"
+)
+
+func interpret(t *testing.T, source string) (string, error) {
+	return interpretWith(t, NewInterpreter(), source)
+}
+
+func interpretWith(t *testing.T, interpreter *Interpreter, source string) (string, error) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locals, err := resolver.Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	interpreter.Writer = &out
+	interpreter.Resolve(locals)
+	err = interpreter.Interpret(statements)
+	return out.String(), err
+}
+
+func TestInterpreter_VisitBinary_OperandTypes(t *testing.T) {
+	sources := map[string]token.TokenType{
+		"1 + \"a\";":  token.PLUS,
+		"\"a\" - 1;":  token.MINUS,
+		"nil * 2;":    token.STAR,
+		"1 / true;":   token.SLASH,
+		"1 > \"a\";":  token.GREATER,
+		"nil >= nil;": token.GREATER_EQUAL,
+		"false < 1;":  token.LESS,
+		"1 <= \"a\";": token.LESS_EQUAL,
+	}
+
+	for source, operator := range sources {
+		_, err := interpret(t, source)
+
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("expected a 'RuntimeError' evaluating %q", source)
+		}
+
+		if runtimeError.Token.Type != operator {
+			t.Fatalf("expected the error at %v, got %v", operator, runtimeError.Token.Type)
+		}
+	}
+}
+
+func TestInterpreter_VisitCall_NotCallable(t *testing.T) {
+	_, err := interpret(t, "var a = 1;\na();")
+
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatal("expected a 'RuntimeError'")
+	}
+
+	if runtimeError.Line != 2 || runtimeError.Token.Type != token.RIGHT_PAREN {
+		t.Fatal("expected the error at the call's closing paren")
+	}
+}
+
+func TestInterpreter_VisitCall_Arity(t *testing.T) {
+	sources := []string{
+		"fun f(a) {}\nf();",
+		"class A { init(a, b) {} }\nA(1);",
+		"class A {}\nA(1);",
+		"clock(1);",
+	}
+
+	for _, source := range sources {
+		_, err := interpret(t, source)
+
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("expected a 'RuntimeError' evaluating %q", source)
+		}
+
+		if runtimeError.Token.Type != token.RIGHT_PAREN {
+			t.Fatalf("expected the error at the call's closing paren evaluating %q", source)
+		}
+	}
+}
+
+func TestInterpreter_DefineNative(t *testing.T) {
+	interpreter := NewInterpreter()
+	interpreter.DefineNative("double", 1, double)
+
+	out, err := interpretWith(t, interpreter, "print double(21);\nprint double;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "42\n<native fn>\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func double(arguments []interface{}) (interface{}, error) {
+	n, ok := arguments[0].(Integer)
+	if !ok {
+		return nil, errors.New("double takes an integer")
+	}
+	return n * 2, nil
+}
+
+func TestInterpreter_DefineNative_Error(t *testing.T) {
+	interpreter := NewInterpreter()
+	interpreter.DefineNative("double", 1, double)
+
+	sources := []string{
+		`print double("a");`,
+		`fun f() { return double("a"); } f();`,
+	}
+	for _, source := range sources {
+		out, err := interpretWith(t, interpreter, source)
+
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("expected a 'RuntimeError' evaluating %q", source)
+		}
+		if runtimeError.Message != "double takes an integer" || runtimeError.Token.Type != token.RIGHT_PAREN {
+			t.Fatalf("unexpected error %v evaluating %q", runtimeError, source)
+		}
+		if out != "" {
+			t.Fatalf("expected nothing to be printed, got %q", out)
+		}
+	}
+}
+
+func TestInterpreter_VisitPrint_Stringify(t *testing.T) {
+	source := `
+print nil;
+print true;
+print 42;
+print 2.5;
+print -3;
+print "a" + "b";
+fun f() {}
+print f;
+class C {}
+print C;
+print C();
+`
+	out, err := interpret(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "nil\ntrue\n42\n2.5\n-3\nab\n<fn f>\nC\nC instance\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestInterpreter_Semantics(t *testing.T) {
+	sources := map[string]string{
+		"print !nil;":           "true",
+		"print !0;":             "false",
+		"print !\"\";":          "false",
+		"print nil == nil;":     "true",
+		"print nil == false;":   "false",
+		"print 1 == \"1\";":     "false",
+		"print \"a\" == \"a\";": "true",
+		"print 0 and 1;":        "1",
+		"print nil or \"x\";":   "x",
+	}
+
+	for source, expected := range sources {
+		out, err := interpret(t, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out != expected+"\n" {
+			t.Fatalf("expected %q evaluating %q, got %q", expected, source, out)
+		}
+	}
+}
+
+func TestInterpreter_Closures(t *testing.T) {
+	// The counter's local only survives between calls if each function's
+	// environment encloses the one it was declared in, not the globals.
+	source := `
+fun makeCounter() {
+  var count = 0;
+  fun increment() {
+    count = count + 1;
+    return count;
+  }
+  return increment;
+}
+var counter = makeCounter();
+print counter();
+print counter();
+var other = makeCounter();
+print other();
+`
+	out, err := interpret(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "1\n2\n1\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestInterpreter_Inheritance(t *testing.T) {
+	source := `
+class A {
+  name() { return "A"; }
+  greet() { return "hello from " + this.name(); }
+}
+class B < A {
+  name() { return "B"; }
+}
+class C < B {
+  greet() { return "C says " + super.greet(); }
+}
+print B().greet();
+print C().greet();
+print C().name();
+`
+	out, err := interpret(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "hello from B\nC says hello from B\nB\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestInterpreter_VisitClass_Superclass(t *testing.T) {
+	sources := map[string]string{
+		"class A < A {}":             "a class can't inherit from itself",
+		"var A = 1;\nclass B < A {}": "superclass must be a class",
+	}
+
+	for source, message := range sources {
+		// Skip the resolver, which would report inheriting from itself first.
+		tokens, err := scanner.Scan(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statements, err := parser.Parse(tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewInterpreter().Interpret(statements)
+
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("expected a 'RuntimeError' evaluating %q", source)
+		}
+
+		if runtimeError.Message != message || runtimeError.Token.Lexeme != "A" {
+			t.Fatalf("unexpected error %v evaluating %q", runtimeError, source)
+		}
+	}
+}
+
+func TestInterpreter_VisitSuper_Unresolved(t *testing.T) {
+	// Without the resolver's distances 'super' can't be found, which should
+	// stop the script rather than crash the host.
+	source := "class A { m() {} }\nclass B < A { m() { super.m(); } }\nB().m();"
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewInterpreter().Interpret(statements)
+
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("expected a 'RuntimeError', got %v", err)
+	}
+	if runtimeError.Token.Lexeme != "super" || runtimeError.Line != 2 {
+		t.Fatalf("unexpected error %v", runtimeError)
+	}
+}
+
+func TestInterpreter_VisitUnary_OperandType(t *testing.T) {
+	_, err := interpret(t, "-\"a\";")
+
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatal("expected a 'RuntimeError'")
+	}
+
+	if runtimeError.Message != "operand must be a number" {
+		t.Fatalf("unexpected message %q", runtimeError.Message)
+	}
+}
+
+func TestInterpreter_VisitBinary_Numbers(t *testing.T) {
+	sources := map[string]string{
+		"print 16777216 + 1;":            "16777217",
+		"print 7 / 2;":                   "3",
+		"print 7.0 / 2;":                 "3.5",
+		"print -7 % 3;":                  "-1",
+		"print 7.5 % 2;":                 "1.5",
+		"print 2.0;":                     "2",
+		"print 1 == 1.0;":                "true",
+		"print 1 < 1.5;":                 "true",
+		"print 9223372036854775807 + 1;": "9223372036854775808",
+		"print 9223372036854775807 + 1 - 1 == 9223372036854775807;": "true",
+		"print -(-9223372036854775807 - 1);":                        "9223372036854775808",
+		"print 4294967296 * 4294967296;":                            "18446744073709551616",
+		"print 0x10 * 1_000;":                                       "16000",
+	}
+
+	for source, expected := range sources {
+		out, err := interpret(t, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out != expected+"\n" {
+			t.Fatalf("expected %q evaluating %q, got %q", expected, source, out)
+		}
+	}
+}
+
+func TestInterpreter_VisitBinary_DivisionByZero(t *testing.T) {
+	for _, source := range []string{"1 / 0;", "1 % 0;"} {
+		_, err := interpret(t, source)
+
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) {
+			t.Fatalf("expected a 'RuntimeError' evaluating %q", source)
+		}
+	}
+}
+
+func TestInterpreter_Heap_Limit(t *testing.T) {
+	interpreter := NewInterpreter()
+	interpreter.Heap.Limit = 1 << 16
+
+	source := `
+var s = "x";
+while (true) s = s + s;
+`
+	_, err := interpretWith(t, interpreter, source)
+
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatal("expected a 'RuntimeError'")
+	}
+
+	if runtimeError.Message != "heap limit of 65536 bytes exceeded" || runtimeError.Token.Type != token.PLUS {
+		t.Fatalf("unexpected error %v", runtimeError)
+	}
+
+	if interpreter.Heap.Peak > interpreter.Heap.Limit {
+		t.Fatalf("peak of %d bytes went over the limit", interpreter.Heap.Peak)
+	}
+}
+
+func TestInterpreter_Heap_ReleasesScopes(t *testing.T) {
+	interpreter := NewInterpreter()
+	baseline := interpreter.Heap.Current
+
+	source := `
+fun f(n) {
+  var s = "some string";
+  return n;
+}
+for (var i = 0; i < 100; i = i + 1) {
+  var a = "block local";
+  f(i);
+}
+`
+	_, err := interpretWith(t, interpreter, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the global 'f' should still be charged.
+	if interpreter.Heap.Current != baseline+EntrySize("f", &LoxFunction{}) {
+		t.Fatalf("expected scopes to be released, %d bytes still in use", interpreter.Heap.Current-baseline)
+	}
+
+	if interpreter.Heap.Peak <= interpreter.Heap.Current {
+		t.Fatal("expected the peak to include the released scopes")
+	}
+}
+
+func TestInterpreter_Heap_KeepsCapturedScopes(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	source := `
+var counter;
+{
+  var count = 0;
+  fun increment() { count = count + 1; }
+  counter = increment;
+}
+`
+	_, err := interpretWith(t, interpreter, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used := interpreter.Heap.Current
+	_, err = interpretWith(t, interpreter, "counter = nil;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if used-interpreter.Heap.Current != FUNCTION_SIZE {
+		t.Fatalf("expected the closure's scope to stay charged, freed %d bytes", used-interpreter.Heap.Current)
+	}
+}
+
+func TestInterpreter_Heap_TemporaryInstances(t *testing.T) {
+	interpreter := NewInterpreter()
+	interpreter.Heap.Limit = 1 << 16
+
+	source := `
+class P {
+  init(x) { this.x = x; this.self = this; }
+}
+var sum = 0;
+for (var i = 0; i < 200000; i = i + 1) {
+  var p = P(i);
+  sum = sum + p.x;
+}
+print sum;
+`
+	out, err := interpretWith(t, interpreter, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "19999900000\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestInterpreter_Heap_GrowingInstances(t *testing.T) {
+	interpreter := NewInterpreter()
+	interpreter.Heap.Limit = 1 << 16
+
+	source := `
+class Node {
+  init(next) {
+    this.next = next;
+    this.name = "a node with a forty character long name";
+  }
+}
+var head = nil;
+while (true) head = Node(head);
+`
+	_, err := interpretWith(t, interpreter, source)
+
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatal("expected a 'RuntimeError'")
+	}
+
+	if runtimeError.Message != "heap limit of 65536 bytes exceeded" {
+		t.Fatalf("unexpected error %v", runtimeError)
+	}
+}
+
+func TestInterpreter_TailCalls(t *testing.T) {
+	// A million nested Lox calls need far more than this without tail calls.
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	source := `
+fun countdown(n) {
+  if (n == 0) return "done";
+  return countdown(n - 1);
+}
+print countdown(1000000);
+
+fun isEven(n) {
+  if (n == 0) return true;
+  return (isOdd(n - 1));
+}
+fun isOdd(n) {
+  if (n == 0) return false;
+  return isEven(n - 1);
+}
+print isEven(1000001);
+`
+	interpreter := NewInterpreter()
+	out, err := interpretWith(t, interpreter, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "done\nfalse\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	if interpreter.Heap.Peak > 1<<12 {
+		t.Fatalf("expected each frame to be released, peak was %d bytes", interpreter.Heap.Peak)
+	}
+}
@@ -0,0 +1,41 @@
+package interpreter
+
+import (
+	"golox/pkg/lox/token"
+	"time"
+)
+
+// NativeFunction is a function written in Go and exposed to scripts. Both
+// backends call Function with arguments already checked against ArgCount, and
+// turn an error it returns into a runtime error at the call.
+type NativeFunction struct {
+	Name     string
+	ArgCount int
+	Function func(arguments []interface{}) (interface{}, error)
+}
+
+func (f *NativeFunction) Arity() int {
+	return f.ArgCount
+}
+
+func (f *NativeFunction) Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	result, err := f.Function(arguments)
+	if err != nil {
+		panic(NewRuntimeError(paren, err.Error()))
+	}
+	return result
+}
+
+func (f *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// Builtins are the native functions every program starts with.
+var Builtins = []*NativeFunction{
+	{Name: "clock", ArgCount: 0, Function: Clock},
+}
+
+// Clock returns the seconds since the Unix epoch.
+func Clock(arguments []interface{}) (interface{}, error) {
+	return Float(time.Now().UnixNano()) / Float(time.Second), nil
+}
@@ -0,0 +1,202 @@
+package interpreter
+
+import (
+	"golox/pkg/lox/token"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Lox numbers are either an Integer, a Float or, once an Integer operation
+// overflows, a BigInteger. BigIntegers that fit back into an int64 are always
+// normalized to Integer so that equal values have a single representation.
+
+func IsNumber(value interface{}) bool {
+	switch value.(type) {
+	case Integer, Float, BigInteger:
+		return true
+	default:
+		return false
+	}
+}
+
+func Normalize(value *big.Int) interface{} {
+	if value.IsInt64() {
+		return value.Int64()
+	}
+	return value
+}
+
+func ToBig(value interface{}) *big.Int {
+	switch value.(type) {
+	case Integer:
+		return big.NewInt(value.(Integer))
+	case BigInteger:
+		return value.(BigInteger)
+	default:
+		panic("not an integer")
+	}
+}
+
+func ToFloat(value interface{}) Float {
+	switch value.(type) {
+	case Integer:
+		return Float(value.(Integer))
+	case Float:
+		return value.(Float)
+	case BigInteger:
+		f, _ := new(big.Float).SetInt(value.(BigInteger)).Float64()
+		return f
+	default:
+		panic("not a number")
+	}
+}
+
+func IsFloat(a interface{}, b interface{}) bool {
+	_, aok := a.(Float)
+	_, bok := b.(Float)
+	return aok || bok
+}
+
+func Add(a interface{}, b interface{}) interface{} {
+	if IsFloat(a, b) {
+		return ToFloat(a) + ToFloat(b)
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok {
+		sum := x + y
+		if (x^sum)&(y^sum) >= 0 {
+			return sum
+		}
+	}
+
+	return Normalize(new(big.Int).Add(ToBig(a), ToBig(b)))
+}
+
+func Subtract(a interface{}, b interface{}) interface{} {
+	if IsFloat(a, b) {
+		return ToFloat(a) - ToFloat(b)
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok {
+		difference := x - y
+		if (x^y)&(x^difference) >= 0 {
+			return difference
+		}
+	}
+
+	return Normalize(new(big.Int).Sub(ToBig(a), ToBig(b)))
+}
+
+func Multiply(a interface{}, b interface{}) interface{} {
+	if IsFloat(a, b) {
+		return ToFloat(a) * ToFloat(b)
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok {
+		if x == 0 || y == 0 {
+			return Integer(0)
+		}
+		product := x * y
+		if product/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64) {
+			return product
+		}
+	}
+
+	return Normalize(new(big.Int).Mul(ToBig(a), ToBig(b)))
+}
+
+func Divide(operator token.Token, a interface{}, b interface{}) interface{} {
+	if IsFloat(a, b) {
+		return ToFloat(a) / ToFloat(b)
+	}
+
+	if ToBig(b).Sign() == 0 {
+		panic(NewRuntimeError(operator, "division by zero"))
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok && !(x == math.MinInt64 && y == -1) {
+		return x / y
+	}
+
+	return Normalize(new(big.Int).Quo(ToBig(a), ToBig(b)))
+}
+
+func Modulo(operator token.Token, a interface{}, b interface{}) interface{} {
+	if IsFloat(a, b) {
+		return math.Mod(ToFloat(a), ToFloat(b))
+	}
+
+	if ToBig(b).Sign() == 0 {
+		panic(NewRuntimeError(operator, "division by zero"))
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok {
+		return x % y
+	}
+
+	return Normalize(new(big.Int).Rem(ToBig(a), ToBig(b)))
+}
+
+func Negate(a interface{}) interface{} {
+	switch a.(type) {
+	case Float:
+		return -a.(Float)
+	case Integer:
+		if a.(Integer) != math.MinInt64 {
+			return -a.(Integer)
+		}
+	}
+	return Normalize(new(big.Int).Neg(ToBig(a)))
+}
+
+// Compare orders two numbers. ok is false when either operand is NaN, in which
+// case every ordering comparison is false.
+func Compare(a interface{}, b interface{}) (result int, ok bool) {
+	if IsFloat(a, b) {
+		x, y := ToFloat(a), ToFloat(b)
+		switch {
+		case math.IsNaN(x) || math.IsNaN(y):
+			return 0, false
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	x, xok := a.(Integer)
+	y, yok := b.(Integer)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	return ToBig(a).Cmp(ToBig(b)), true
+}
+
+func FormatFloat(value Float) string {
+	magnitude := math.Abs(value)
+	if magnitude != 0 && (magnitude < 1e-6 || magnitude >= 1e21) {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -1,20 +1,81 @@
 package interpreter
 
+import (
+	"fmt"
+	"golox/pkg/lox/token"
+	"math/big"
+	"strconv"
+)
+
 type Boolean = bool
-type Number = float32
+type Integer = int64
+type Float = float64
+type BigInteger = *big.Int
 type String = string
 
 func IsTruthy(value interface{}) bool {
 	switch value.(type) {
-	case Boolean:
-		return value.(Boolean) == true
-	case Number:
-		return value.(Number) != Number(0.0)
-	case String:
-		return len(value.(String)) != 0
 	case nil:
 		return false
+	case Boolean:
+		return value.(Boolean)
 	default:
-		panic("invalid lox type")
+		return true
+	}
+}
+
+func IsEqual(a interface{}, b interface{}) bool {
+	if a == nil && b == nil {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if IsNumber(a) && IsNumber(b) {
+		result, ok := Compare(a, b)
+		return ok && result == 0
+	}
+	return a == b
+}
+
+func Stringify(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case Boolean:
+		return strconv.FormatBool(value.(Boolean))
+	case Integer:
+		return strconv.FormatInt(value.(Integer), 10)
+	case Float:
+		return FormatFloat(value.(Float))
+	case BigInteger:
+		return value.(BigInteger).String()
+	case String:
+		return value.(String)
+	case fmt.Stringer:
+		return value.(fmt.Stringer).String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Describe is Stringify with strings quoted, so that they can be told apart
+// from other values in diagnostic output.
+func Describe(value interface{}) string {
+	if s, ok := value.(String); ok {
+		return strconv.Quote(s)
+	}
+	return Stringify(value)
+}
+
+func CheckNumberOperand(operator token.Token, operand interface{}) {
+	if !IsNumber(operand) {
+		panic(NewRuntimeError(operator, "operand must be a number"))
+	}
+}
+
+func CheckNumberOperands(operator token.Token, left interface{}, right interface{}) {
+	if !IsNumber(left) || !IsNumber(right) {
+		panic(NewRuntimeError(operator, "operands must be numbers"))
 	}
 }
@@ -0,0 +1,307 @@
+// Package optimizer rewrites syntax trees before they run, folding constant
+// expressions and dropping branches that can never be taken.
+package optimizer
+
+import (
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/token"
+)
+
+// Optimize returns the optimized statements together with a list of the
+// rewrites it made. It must run after the resolver: the nodes the resolver
+// keys scope depths on are kept, updated in place, and code that is removed
+// here still has its static errors reported.
+func Optimize(statements []ast.Statement) ([]ast.Statement, []Change) {
+	optimizer := NewOptimizer()
+	statements = optimizer.OptimizeStatements(statements)
+	return statements, optimizer.Changes
+}
+
+type Change struct {
+	Range   token.Span
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Range.Start, c.Message)
+}
+
+type Optimizer struct {
+	Interpreter *interpreter.Interpreter
+	Changes     []Change
+}
+
+func NewOptimizer() *Optimizer {
+	return &Optimizer{
+		Interpreter: interpreter.NewInterpreter(),
+		Changes:     make([]Change, 0),
+	}
+}
+
+func (o *Optimizer) Report(span token.Span, format string, args ...interface{}) {
+	o.Changes = append(o.Changes, Change{
+		Range:   span,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (o *Optimizer) OptimizeStatements(statements []ast.Statement) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(statements))
+	for _, statement := range statements {
+		statement = o.OptimizeStatement(statement)
+		if statement != nil {
+			optimized = append(optimized, statement)
+		}
+	}
+	return optimized
+}
+
+// OptimizeStatement returns the rewritten statement, or nil if it can be
+// dropped altogether.
+func (o *Optimizer) OptimizeStatement(statement ast.Statement) ast.Statement {
+	if statement == nil {
+		return nil
+	}
+	optimized, _ := statement.Accept(o).(ast.Statement)
+	return optimized
+}
+
+// OptimizeBody is OptimizeStatement for places that need a statement even if
+// this one was dropped, such as a loop body.
+func (o *Optimizer) OptimizeBody(statement ast.Statement) ast.Statement {
+	optimized := o.OptimizeStatement(statement)
+	if optimized == nil {
+		return ast.Block{
+			Statements: make([]ast.Statement, 0),
+			Range:      statement.Span(),
+		}
+	}
+	return optimized
+}
+
+func (o *Optimizer) OptimizeExpression(expr ast.Expression) ast.Expression {
+	if expr == nil {
+		return nil
+	}
+	return expr.Accept(o).(ast.Expression)
+}
+
+// Fold evaluates an expression whose operands are all literals. It reports
+// false if evaluating it raises a runtime error, so that the error is still
+// raised when the program runs.
+func (o *Optimizer) Fold(expr ast.Expression) (value interface{}, ok bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		_, isRuntimeError := r.(*interpreter.RuntimeError)
+		if !isRuntimeError {
+			panic(r)
+		}
+
+		ok = false
+	}()
+
+	return expr.Accept(o.Interpreter), true
+}
+
+func IsLiteral(expr ast.Expression) bool {
+	_, ok := expr.(ast.Literal)
+	return ok
+}
+
+func LiteralValue(expr ast.Expression) interface{} {
+	return expr.(ast.Literal).Value
+}
+
+func (o *Optimizer) VisitAssignment(expr *ast.Assignment) interface{} {
+	expr.Value = o.OptimizeExpression(expr.Value)
+	return expr
+}
+
+func (o *Optimizer) VisitBinary(expr *ast.Binary) interface{} {
+	expr.Left = o.OptimizeExpression(expr.Left)
+	expr.Right = o.OptimizeExpression(expr.Right)
+
+	if IsLiteral(expr.Left) && IsLiteral(expr.Right) {
+		value, ok := o.Fold(*expr)
+		if ok {
+			o.Report(expr.Range, "folded %s %s %s to %s", interpreter.Describe(LiteralValue(expr.Left)), expr.Operation.Lexeme, interpreter.Describe(LiteralValue(expr.Right)), interpreter.Describe(value))
+			return ast.Literal{Value: value, Range: expr.Range}
+		}
+	}
+
+	return *expr
+}
+
+func (o *Optimizer) VisitCall(expr *ast.Call) interface{} {
+	expr.Callee = o.OptimizeExpression(expr.Callee)
+	arguments := make([]ast.Expression, 0, len(expr.Arguments))
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, o.OptimizeExpression(argument))
+	}
+	expr.Arguments = arguments
+	return *expr
+}
+
+func (o *Optimizer) VisitGet(expr *ast.Get) interface{} {
+	expr.Object = o.OptimizeExpression(expr.Object)
+	return *expr
+}
+
+func (o *Optimizer) VisitGrouping(expr *ast.Grouping) interface{} {
+	expr.Expr = o.OptimizeExpression(expr.Expr)
+	if IsLiteral(expr.Expr) {
+		return ast.Literal{Value: LiteralValue(expr.Expr), Range: expr.Range}
+	}
+	return *expr
+}
+
+func (o *Optimizer) VisitLiteral(expr *ast.Literal) interface{} {
+	return *expr
+}
+
+// VisitLogical folds 'and' and 'or' whenever the left operand is constant.
+// The right operand need not be: it either never runs or becomes the result.
+func (o *Optimizer) VisitLogical(expr *ast.Logical) interface{} {
+	expr.Left = o.OptimizeExpression(expr.Left)
+	expr.Right = o.OptimizeExpression(expr.Right)
+
+	if !IsLiteral(expr.Left) {
+		return *expr
+	}
+
+	left := LiteralValue(expr.Left)
+	if interpreter.IsTruthy(left) == (expr.Operation.Type == token.OR) {
+		o.Report(expr.Range, "folded %s %s ... to %s", interpreter.Describe(left), expr.Operation.Lexeme, interpreter.Describe(left))
+		return ast.Literal{Value: left, Range: expr.Range}
+	}
+
+	o.Report(expr.Range, "folded %s %s ... to its right operand", interpreter.Describe(left), expr.Operation.Lexeme)
+	return expr.Right
+}
+
+func (o *Optimizer) VisitSet(expr *ast.Set) interface{} {
+	expr.Object = o.OptimizeExpression(expr.Object)
+	expr.Value = o.OptimizeExpression(expr.Value)
+	return *expr
+}
+
+func (o *Optimizer) VisitSuper(expr *ast.Super) interface{} {
+	return expr
+}
+
+func (o *Optimizer) VisitThis(expr *ast.This) interface{} {
+	return expr
+}
+
+func (o *Optimizer) VisitUnary(expr *ast.Unary) interface{} {
+	expr.Operand = o.OptimizeExpression(expr.Operand)
+
+	if IsLiteral(expr.Operand) {
+		value, ok := o.Fold(*expr)
+		if ok {
+			o.Report(expr.Range, "folded %s(%s) to %s", expr.Operation.Lexeme, interpreter.Describe(LiteralValue(expr.Operand)), interpreter.Describe(value))
+			return ast.Literal{Value: value, Range: expr.Range}
+		}
+	}
+
+	return *expr
+}
+
+func (o *Optimizer) VisitVariable(expr *ast.Variable) interface{} {
+	return expr
+}
+
+func (o *Optimizer) VisitBlock(stmt *ast.Block) interface{} {
+	stmt.Statements = o.OptimizeStatements(stmt.Statements)
+	return *stmt
+}
+
+func (o *Optimizer) VisitBreak(stmt *ast.Break) interface{} {
+	return *stmt
+}
+
+func (o *Optimizer) VisitClass(stmt *ast.Class) interface{} {
+	methods := make([]ast.Function, 0, len(stmt.Methods))
+	for index := range stmt.Methods {
+		methods = append(methods, o.VisitFunction(&stmt.Methods[index]).(ast.Function))
+	}
+	stmt.Methods = methods
+	return *stmt
+}
+
+func (o *Optimizer) VisitContinue(stmt *ast.Continue) interface{} {
+	return *stmt
+}
+
+func (o *Optimizer) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
+	stmt.Expr = o.OptimizeExpression(stmt.Expr)
+	return *stmt
+}
+
+func (o *Optimizer) VisitFunction(stmt *ast.Function) interface{} {
+	function := *stmt
+	function.Body = o.OptimizeStatements(stmt.Body)
+	return function
+}
+
+func (o *Optimizer) VisitIf(stmt *ast.If) interface{} {
+	stmt.Condition = o.OptimizeExpression(stmt.Condition)
+
+	if !IsLiteral(stmt.Condition) {
+		stmt.ThenBranch = o.OptimizeBody(stmt.ThenBranch)
+		stmt.ElseBranch = o.OptimizeStatement(stmt.ElseBranch)
+		return *stmt
+	}
+
+	condition := LiteralValue(stmt.Condition)
+	if interpreter.IsTruthy(condition) {
+		if stmt.ElseBranch != nil {
+			o.Report(stmt.Range, "removed else branch of if with constant %s condition", interpreter.Describe(condition))
+		} else {
+			o.Report(stmt.Range, "replaced if with constant %s condition by its body", interpreter.Describe(condition))
+		}
+		return o.OptimizeStatement(stmt.ThenBranch)
+	}
+
+	if stmt.ElseBranch != nil {
+		o.Report(stmt.Range, "removed then branch of if with constant %s condition", interpreter.Describe(condition))
+		return o.OptimizeStatement(stmt.ElseBranch)
+	}
+
+	o.Report(stmt.Range, "removed if with constant %s condition", interpreter.Describe(condition))
+	return nil
+}
+
+func (o *Optimizer) VisitPrint(stmt *ast.Print) interface{} {
+	stmt.Expr = o.OptimizeExpression(stmt.Expr)
+	return *stmt
+}
+
+func (o *Optimizer) VisitReturn(stmt *ast.Return) interface{} {
+	stmt.Value = o.OptimizeExpression(stmt.Value)
+	return *stmt
+}
+
+func (o *Optimizer) VisitVar(stmt *ast.Var) interface{} {
+	stmt.Initializer = o.OptimizeExpression(stmt.Initializer)
+	return *stmt
+}
+
+func (o *Optimizer) VisitWhile(stmt *ast.While) interface{} {
+	stmt.Condition = o.OptimizeExpression(stmt.Condition)
+
+	if IsLiteral(stmt.Condition) && !interpreter.IsTruthy(LiteralValue(stmt.Condition)) {
+		o.Report(stmt.Range, "removed while loop with constant %s condition", interpreter.Describe(LiteralValue(stmt.Condition)))
+		return nil
+	}
+
+	stmt.Body = o.OptimizeBody(stmt.Body)
+	stmt.Increment = o.OptimizeExpression(stmt.Increment)
+	return *stmt
+}
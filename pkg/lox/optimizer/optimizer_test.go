@@ -0,0 +1,74 @@
+package optimizer
+
+import (
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/resolver"
+	"golox/pkg/lox/scanner"
+	"testing"
+)
+
+func optimize(t *testing.T, source string) (string, []Change) {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resolver.Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, changes := Optimize(statements)
+	return ast.Printer{}.Print(statements), changes
+}
+
+func TestOptimize_Folding(t *testing.T) {
+	sources := map[string]string{
+		"print 1 + 2 * 3;":             "(print\n  (literal 7))\n",
+		"print \"a\" + \"b\";":         "(print\n  (literal \"ab\"))\n",
+		"print -(1.5);":                "(print\n  (literal -1.5))\n",
+		"print !nil;":                  "(print\n  (literal true))\n",
+		"print 1 < 2 and x;":           "(print\n  (variable x))\n",
+		"print nil or x;":              "(print\n  (variable x))\n",
+		"print 0 or x;":                "(print\n  (literal 0))\n",
+		"if (false) print 1;":          "",
+		"if (nil) print 1; else x;":    "(expression\n  (variable x))\n",
+		"while (1 > 2) print 1;":       "",
+		"while (x) if (false) x;":      "(while\n  (variable x)\n  (block))\n",
+		"fun f() { return 2 * 2; }":    "(function f ()\n  (return\n    (literal 4)))\n",
+		"x = 9223372036854775807 + 1;": "(expression\n  (assignment x\n    (literal 9223372036854775808)))\n",
+	}
+
+	for source, expected := range sources {
+		output, changes := optimize(t, source)
+		if output != expected {
+			t.Fatalf("expected:\n%s\noptimizing %q, got:\n%s", expected, source, output)
+		}
+		if len(changes) == 0 {
+			t.Fatalf("expected changes to be reported optimizing %q", source)
+		}
+	}
+}
+
+func TestOptimize_PreservesRuntimeErrors(t *testing.T) {
+	sources := []string{
+		"print 1 / 0;",
+		"print 1 % 0;",
+		"print -\"a\";",
+		"print \"a\" + 1;",
+		"print nil < 1;",
+	}
+
+	for _, source := range sources {
+		_, changes := optimize(t, source)
+		if len(changes) != 0 {
+			t.Fatalf("expected %q to be left alone, got %v", source, changes)
+		}
+	}
+}
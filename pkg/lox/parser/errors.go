@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+	"golox/pkg/lox/token"
+)
+
+type ParseError struct {
+	Token   token.Token
+	Line    int
+	Column  int
+	Message string
+}
+
+func NewParseError(t token.Token, message string) *ParseError {
+	return &ParseError{
+		Token:   t,
+		Line:    t.Line,
+		Column:  t.Column,
+		Message: message,
+	}
+}
+
+func (e *ParseError) Error() string {
+	if e.Token.Type == token.EOF {
+		return fmt.Sprintf("[line %d:%d] error at end: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("[line %d:%d] error at '%s': %s", e.Line, e.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *ParseError) Span() token.Span {
+	return e.Token.Span()
+}
+
+func (e *ParseError) Reason() string {
+	return e.Message
+}
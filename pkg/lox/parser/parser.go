@@ -1,38 +1,64 @@
 package parser
 
 import (
+	"errors"
 	"golox/pkg/lox/ast"
 	"golox/pkg/lox/token"
 )
 
-func Parse(tokens []token.Token) []ast.Statement {
+func Parse(tokens []token.Token) ([]ast.Statement, error) {
 	parser := NewParser(tokens)
 	return parser.Parse()
 }
 
 type Parser struct {
-	Tokens  []token.Token
-	Current int
+	Tokens    []token.Token
+	Errors    []error
+	Current   int
+	LoopDepth int
 }
 
 func NewParser(tokens []token.Token) *Parser {
 	return &Parser{
 		Tokens:  tokens,
+		Errors:  make([]error, 0),
 		Current: 0,
 	}
 }
 
-func (p *Parser) Parse() []ast.Statement {
+func (p *Parser) Parse() ([]ast.Statement, error) {
 	statements := make([]ast.Statement, 0)
 	for !p.IsAtEnd() {
-		statements = append(statements, p.ParseDeclaration())
+		declaration := p.ParseDeclaration()
+		if declaration != nil {
+			statements = append(statements, declaration)
+		}
 	}
-	return statements
+	return statements, errors.Join(p.Errors...)
 }
 
-func (p *Parser) ParseDeclaration() ast.Statement {
+func (p *Parser) ParseDeclaration() (declaration ast.Statement) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		parseError, ok := r.(*ParseError)
+		if !ok {
+			panic(r)
+		}
+
+		p.Errors = append(p.Errors, parseError)
+		p.Synchronize()
+		declaration = nil
+	}()
+
+	if p.Match(token.CLASS) {
+		return p.ParseClassDeclaration()
+	}
 	if p.Match(token.FUN) {
-		return p.ParseFunctionDeclaration()
+		return p.ParseFunctionDeclaration("function")
 	}
 	if p.Match(token.VAR) {
 		return p.ParseVarDeclaration()
@@ -40,9 +66,44 @@ func (p *Parser) ParseDeclaration() ast.Statement {
 	return p.ParseStatement()
 }
 
-func (p *Parser) ParseFunctionDeclaration() ast.Statement {
-	name := p.Consume(token.IDENTIFIER, "expect function name")
-	p.Consume(token.LEFT_PAREN, "expect '(' after function name")
+func (p *Parser) ParseClassDeclaration() ast.Statement {
+	keyword := p.Previous()
+	name := p.Consume(token.IDENTIFIER, "expect class name")
+
+	var superclass *ast.Variable
+	if p.Match(token.LESS) {
+		p.Consume(token.IDENTIFIER, "expect superclass name")
+		superclass = &ast.Variable{
+			Name:  p.Previous(),
+			Range: p.Previous().Span(),
+		}
+	}
+
+	p.Consume(token.LEFT_BRACE, "expect '{' before class body")
+
+	methods := make([]ast.Function, 0)
+	for !p.Check(token.RIGHT_BRACE) && !p.IsAtEnd() {
+		methods = append(methods, p.ParseFunctionDeclaration("method"))
+	}
+
+	p.Consume(token.RIGHT_BRACE, "expect '}' after class body")
+
+	return ast.Class{
+		Name:       name,
+		Superclass: superclass,
+		Methods:    methods,
+		Range:      p.SpanFrom(keyword),
+	}
+}
+
+func (p *Parser) ParseFunctionDeclaration(kind string) ast.Function {
+	start := p.Peek()
+	if p.Previous().Type == token.FUN {
+		start = p.Previous()
+	}
+
+	name := p.Consume(token.IDENTIFIER, "expect "+kind+" name")
+	p.Consume(token.LEFT_PAREN, "expect '(' after "+kind+" name")
 	parameters := make([]token.Token, 0)
 	if !p.Check(token.RIGHT_PAREN) {
 		parameters = append(parameters, p.Consume(token.IDENTIFIER, "expect parameter name"))
@@ -52,17 +113,23 @@ func (p *Parser) ParseFunctionDeclaration() ast.Statement {
 	}
 	p.Consume(token.RIGHT_PAREN, "expect ')' after parameters")
 
-	p.Consume(token.LEFT_BRACE, "expect '{' before function body")
+	p.Consume(token.LEFT_BRACE, "expect '{' before "+kind+" body")
+
+	// A loop around the declaration doesn't make 'break' valid in its body.
+	defer func(depth int) { p.LoopDepth = depth }(p.LoopDepth)
+	p.LoopDepth = 0
 	body := p.ParseBlock()
 
 	return ast.Function{
 		Name:   name,
 		Params: parameters,
 		Body:   body,
+		Range:  p.SpanFrom(start),
 	}
 }
 
 func (p *Parser) ParseVarDeclaration() ast.Statement {
+	keyword := p.Previous()
 	name := p.Consume(token.IDENTIFIER, "expect variable name")
 
 	var initializer ast.Expression
@@ -75,6 +142,7 @@ func (p *Parser) ParseVarDeclaration() ast.Statement {
 	return ast.Var{
 		Name:        name,
 		Initializer: initializer,
+		Range:       p.SpanFrom(keyword),
 	}
 }
 
@@ -88,12 +156,17 @@ func (p *Parser) ParseStatement() ast.Statement {
 	if p.Match(token.RETURN) {
 		return p.ParseReturn()
 	}
+	if p.Match(token.BREAK, token.CONTINUE) {
+		return p.ParseLoopJump()
+	}
 	if p.Match(token.WHILE) {
 		return p.ParseWhileStatement()
 	}
 	if p.Match(token.LEFT_BRACE) {
+		brace := p.Previous()
 		return ast.Block{
 			Statements: p.ParseBlock(),
+			Range:      p.SpanFrom(brace),
 		}
 	}
 	if p.Match(token.FOR) {
@@ -104,7 +177,7 @@ func (p *Parser) ParseStatement() ast.Statement {
 }
 
 func (p *Parser) ParseReturn() ast.Statement {
-	_ = p.Previous()
+	keyword := p.Previous()
 	var value ast.Expression
 	if !p.Check(token.SEMICOLON) {
 		value = p.ParseExpression()
@@ -112,23 +185,58 @@ func (p *Parser) ParseReturn() ast.Statement {
 	p.Consume(token.SEMICOLON, "expected ';' after return value")
 
 	return ast.Return{
-		Value: value,
+		Keyword: keyword,
+		Value:   value,
+		Range:   p.SpanFrom(keyword),
+	}
+}
+
+// ParseLoopJump parses 'break' and 'continue'. Using either outside a loop is
+// reported without discarding the statement, as parsing can carry on.
+func (p *Parser) ParseLoopJump() ast.Statement {
+	keyword := p.Previous()
+	p.Consume(token.SEMICOLON, "expect ';' after '"+keyword.Lexeme+"'")
+
+	if p.LoopDepth == 0 {
+		p.Errors = append(p.Errors, NewParseError(keyword, "can't use '"+keyword.Lexeme+"' outside of a loop"))
+	}
+
+	if keyword.Type == token.BREAK {
+		return ast.Break{
+			Keyword: keyword,
+			Range:   p.SpanFrom(keyword),
+		}
+	}
+	return ast.Continue{
+		Keyword: keyword,
+		Range:   p.SpanFrom(keyword),
 	}
 }
 
+// ParseLoopBody parses the body of a while or for loop, where 'break' and
+// 'continue' are allowed.
+func (p *Parser) ParseLoopBody() ast.Statement {
+	defer func() { p.LoopDepth-- }()
+	p.LoopDepth++
+	return p.ParseStatement()
+}
+
 func (p *Parser) ParseWhileStatement() ast.Statement {
+	keyword := p.Previous()
 	p.Consume(token.LEFT_PAREN, "expect '(' after 'while'")
 	condition := p.ParseExpression()
 	p.Consume(token.RIGHT_PAREN, "expect ')' after while condition")
-	body := p.ParseStatement()
+	body := p.ParseLoopBody()
 
 	return ast.While{
 		Condition: condition,
 		Body:      body,
+		Range:     p.SpanFrom(keyword),
 	}
 }
 
 func (p *Parser) ParseIfStatement() ast.Statement {
+	keyword := p.Previous()
 	p.Consume(token.LEFT_PAREN, "expect '(' after 'if'")
 	condition := p.ParseExpression()
 	p.Consume(token.RIGHT_PAREN, "expect ')' after if condition")
@@ -144,10 +252,12 @@ func (p *Parser) ParseIfStatement() ast.Statement {
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
+		Range:      p.SpanFrom(keyword),
 	}
 }
 
 func (p *Parser) ParseForStatement() ast.Statement {
+	keyword := p.Previous()
 	p.Consume(token.LEFT_PAREN, "expect '(' after 'for'")
 
 	var initializer ast.Statement
@@ -163,7 +273,7 @@ func (p *Parser) ParseForStatement() ast.Statement {
 	if !p.Check(token.SEMICOLON) {
 		condition = p.ParseExpression()
 	}
-	p.Consume(token.SEMICOLON, "expect ';' after loop condition")
+	semicolon := p.Consume(token.SEMICOLON, "expect ';' after loop condition")
 
 	var increment ast.Expression
 	if !p.Check(token.RIGHT_PAREN) {
@@ -171,28 +281,27 @@ func (p *Parser) ParseForStatement() ast.Statement {
 	}
 	p.Consume(token.RIGHT_PAREN, "expect ')' after for clauses")
 
-	body := p.ParseStatement()
-
-	if increment != nil {
-		body = ast.Block{
-			Statements: []ast.Statement{body, increment},
-		}
-	}
+	body := p.ParseLoopBody()
+	span := p.SpanFrom(keyword)
 
 	if condition == nil {
 		condition = ast.Literal{
 			Value: true,
+			Range: semicolon.Span(),
 		}
 	}
 
 	body = ast.While{
 		Condition: condition,
 		Body:      body,
+		Increment: increment,
+		Range:     span,
 	}
 
 	if initializer != nil {
 		body = ast.Block{
 			Statements: []ast.Statement{initializer, body},
+			Range:      span,
 		}
 	}
 
@@ -202,18 +311,23 @@ func (p *Parser) ParseForStatement() ast.Statement {
 func (p *Parser) ParseBlock() []ast.Statement {
 	statements := make([]ast.Statement, 0)
 	for !p.Check(token.RIGHT_BRACE) && !p.IsAtEnd() {
-		statements = append(statements, p.ParseDeclaration())
+		declaration := p.ParseDeclaration()
+		if declaration != nil {
+			statements = append(statements, declaration)
+		}
 	}
 	p.Consume(token.RIGHT_BRACE, "expect '}' after block")
 	return statements
 }
 
 func (p *Parser) ParsePrintStatement() ast.Statement {
+	keyword := p.Previous()
 	value := p.ParseExpression()
 	p.Consume(token.SEMICOLON, "expect ';' after value")
 
 	return ast.Print{
-		Expr: value,
+		Expr:  value,
+		Range: p.SpanFrom(keyword),
 	}
 }
 
@@ -222,7 +336,8 @@ func (p *Parser) ParseExpressionStatement() ast.Statement {
 	p.Consume(token.SEMICOLON, "expect ';' after expression")
 
 	return ast.ExpressionStatement{
-		Expr: expr,
+		Expr:  expr,
+		Range: expr.Span().Join(p.Previous().Span()),
 	}
 }
 
@@ -234,17 +349,29 @@ func (p *Parser) ParseAssignment() ast.Expression {
 	expr := p.ParseOr()
 
 	if p.Match(token.EQUAL) {
+		equals := p.Previous()
 		value := p.ParseAssignment()
 
-		if _, ok := expr.(ast.Variable); ok {
-			name := expr.(ast.Variable).Name
-			return ast.Assignment{
+		if _, ok := expr.(*ast.Variable); ok {
+			name := expr.(*ast.Variable).Name
+			return &ast.Assignment{
 				Name:  name,
 				Value: value,
+				Range: expr.Span().Join(value.Span()),
 			}
 		}
 
-		panic("invalid assignment target")
+		if _, ok := expr.(ast.Get); ok {
+			get := expr.(ast.Get)
+			return ast.Set{
+				Object: get.Object,
+				Name:   get.Name,
+				Value:  value,
+				Range:  expr.Span().Join(value.Span()),
+			}
+		}
+
+		p.Errors = append(p.Errors, NewParseError(equals, "invalid assignment target"))
 	}
 
 	return expr
@@ -260,6 +387,7 @@ func (p *Parser) ParseOr() ast.Statement {
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -276,6 +404,7 @@ func (p *Parser) ParseAnd() ast.Statement {
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -292,6 +421,7 @@ func (p *Parser) ParseEquality() ast.Expression {
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -344,6 +474,7 @@ func (p *Parser) ParseComparison() ast.Expression {
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -360,6 +491,7 @@ func (p *Parser) ParseTerm() ast.Expression {
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -369,13 +501,14 @@ func (p *Parser) ParseTerm() ast.Expression {
 func (p *Parser) ParseFactor() ast.Expression {
 	expr := p.ParseUnary()
 
-	for p.Match(token.SLASH, token.STAR) {
+	for p.Match(token.SLASH, token.STAR, token.PERCENT) {
 		operator := p.Previous()
 		right := p.ParseUnary()
 		expr = ast.Binary{
 			Operation: operator,
 			Left:      expr,
 			Right:     right,
+			Range:     expr.Span().Join(right.Span()),
 		}
 	}
 
@@ -389,6 +522,7 @@ func (p *Parser) ParseUnary() ast.Expression {
 		return ast.Unary{
 			Operation: operator,
 			Operand:   right,
+			Range:     operator.Span().Join(right.Span()),
 		}
 	}
 
@@ -401,6 +535,13 @@ func (p *Parser) ParseCall() ast.Expression {
 	for {
 		if p.Match(token.LEFT_PAREN) {
 			expression = p.FinishCall(expression)
+		} else if p.Match(token.DOT) {
+			name := p.Consume(token.IDENTIFIER, "expect property name after '.'")
+			expression = ast.Get{
+				Object: expression,
+				Name:   name,
+				Range:  expression.Span().Join(name.Span()),
+			}
 		} else {
 			break
 		}
@@ -418,11 +559,13 @@ func (p *Parser) FinishCall(callee ast.Expression) ast.Expression {
 		}
 	}
 
-	_ = p.Consume(token.RIGHT_PAREN, "expect ')' after arguments")
+	paren := p.Consume(token.RIGHT_PAREN, "expect ')' after arguments")
 
 	return ast.Call{
 		Callee:    callee,
+		Paren:     paren,
 		Arguments: arguments,
+		Range:     callee.Span().Join(paren.Span()),
 	}
 }
 
@@ -430,38 +573,86 @@ func (p *Parser) ParsePrimary() ast.Expression {
 	if p.Match(token.FALSE) {
 		return ast.Literal{
 			Value: false,
+			Range: p.Previous().Span(),
 		}
 	}
 	if p.Match(token.TRUE) {
 		return ast.Literal{
 			Value: true,
+			Range: p.Previous().Span(),
 		}
 	}
 	if p.Match(token.NIL) {
 		return ast.Literal{
 			Value: nil,
+			Range: p.Previous().Span(),
 		}
 	}
 
 	if p.Match(token.NUMBER, token.STRING) {
-		return ast.Literal{Value: p.Previous().Literal}
+		return ast.Literal{
+			Value: p.Previous().Literal,
+			Range: p.Previous().Span(),
+		}
+	}
+
+	if p.Match(token.SUPER) {
+		keyword := p.Previous()
+		p.Consume(token.DOT, "expect '.' after 'super'")
+		method := p.Consume(token.IDENTIFIER, "expect superclass method name")
+		return &ast.Super{
+			Keyword: keyword,
+			Method:  method,
+			Range:   keyword.Span().Join(method.Span()),
+		}
+	}
+
+	if p.Match(token.THIS) {
+		return &ast.This{
+			Keyword: p.Previous(),
+			Range:   p.Previous().Span(),
+		}
 	}
 
 	if p.Match(token.IDENTIFIER) {
-		return ast.Variable{
-			Name: p.Previous(),
+		return &ast.Variable{
+			Name:  p.Previous(),
+			Range: p.Previous().Span(),
 		}
 	}
 
 	if p.Match(token.LEFT_PAREN) {
+		paren := p.Previous()
 		expr := p.ParseExpression()
 		p.Consume(token.RIGHT_PAREN, "expect ')' after expression")
 		return ast.Grouping{
-			Expr: expr,
+			Expr:  expr,
+			Range: p.SpanFrom(paren),
 		}
 	}
 
-	panic("not supposed to be here!")
+	panic(NewParseError(p.Peek(), "expect expression"))
+}
+
+func (p *Parser) Synchronize() {
+	p.Advance()
+
+	for !p.IsAtEnd() {
+		if p.Previous().Type == token.SEMICOLON {
+			return
+		}
+
+		switch p.Peek().Type {
+		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF, token.WHILE, token.PRINT, token.RETURN, token.BREAK, token.CONTINUE:
+			return
+		}
+
+		p.Advance()
+	}
+}
+
+func (p *Parser) SpanFrom(start token.Token) token.Span {
+	return start.Span().Join(p.Previous().Span())
 }
 
 func (p *Parser) Consume(typ token.TokenType, message string) token.Token {
@@ -469,5 +660,5 @@ func (p *Parser) Consume(typ token.TokenType, message string) token.Token {
 		return p.Advance()
 	}
 
-	panic(message)
+	panic(NewParseError(p.Peek(), message))
 }
@@ -1,13 +1,112 @@
 package parser
 
 import (
+	"errors"
 	"golox/pkg/lox/ast"
+	"golox/pkg/lox/scanner"
 	"golox/pkg/lox/token"
 	"testing"
 )
 
 func TestParser_ParseDeclaration_ClassDeclaration(t *testing.T) {
-	t.Skip("not implemented")
+	tokens := []token.Token{
+		{Type: token.CLASS, Lexeme: "class", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "Point", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "init", Line: 2},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 2},
+		{Type: token.IDENTIFIER, Lexeme: "x", Line: 2},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 2},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 2},
+		{Type: token.THIS, Lexeme: "this", Line: 3},
+		{Type: token.DOT, Lexeme: ".", Line: 3},
+		{Type: token.IDENTIFIER, Lexeme: "x", Line: 3},
+		{Type: token.EQUAL, Lexeme: "=", Line: 3},
+		{Type: token.IDENTIFIER, Lexeme: "x", Line: 3},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 3},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 4},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 5},
+		{Type: token.EOF, Lexeme: "", Line: 6},
+	}
+
+	parser := NewParser(tokens)
+	declaration := parser.ParseDeclaration()
+
+	_, ok := declaration.(ast.Class)
+	if !ok {
+		t.Fatal("expected a 'Class' statement")
+	}
+
+	if declaration.(ast.Class).Name.Lexeme != "Point" {
+		t.Fatal("expected identifier 'Point'")
+	}
+
+	if len(declaration.(ast.Class).Methods) != 1 {
+		t.Fatal("expected one method")
+	}
+
+	body := declaration.(ast.Class).Methods[0].Body
+	if len(body) != 1 {
+		t.Fatal("expected one statement")
+	}
+
+	_, ok = body[0].(ast.ExpressionStatement).Expr.(ast.Set)
+	if !ok {
+		t.Fatal("expected a 'Set' expression")
+	}
+}
+
+func TestParser_ParseDeclaration_ClassDeclaration_Superclass(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.CLASS, Lexeme: "class", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "B", Line: 1},
+		{Type: token.LESS, Lexeme: "<", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "A", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "method", Line: 2},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 2},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 2},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 2},
+		{Type: token.SUPER, Lexeme: "super", Line: 3},
+		{Type: token.DOT, Lexeme: ".", Line: 3},
+		{Type: token.IDENTIFIER, Lexeme: "method", Line: 3},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 3},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 3},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 3},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 4},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 5},
+		{Type: token.EOF, Lexeme: "", Line: 6},
+	}
+
+	parser := NewParser(tokens)
+	declaration := parser.ParseDeclaration()
+
+	class, ok := declaration.(ast.Class)
+	if !ok {
+		t.Fatal("expected a 'Class' statement")
+	}
+
+	if class.Superclass == nil || class.Superclass.Name.Lexeme != "A" {
+		t.Fatal("expected superclass 'A'")
+	}
+
+	if len(class.Methods) != 1 || len(class.Methods[0].Body) != 1 {
+		t.Fatal("expected one method with one statement")
+	}
+
+	call, ok := class.Methods[0].Body[0].(ast.ExpressionStatement).Expr.(ast.Call)
+	if !ok {
+		t.Fatal("expected a 'Call' expression")
+	}
+
+	super, ok := call.Callee.(*ast.Super)
+	if !ok {
+		t.Fatal("expected a 'Super' expression")
+	}
+
+	if super.Keyword.Type != token.SUPER || super.Method.Lexeme != "method" {
+		t.Fatal("expected 'super.method'")
+	}
 }
 
 func TestParser_ParseDeclaration_FunctionDeclaration(t *testing.T) {
@@ -16,12 +115,12 @@ func TestParser_ParseDeclaration_FunctionDeclaration(t *testing.T) {
 
 func TestParser_ParseDeclaration_VarDeclaration(t *testing.T) {
 	tokens := []token.Token{
-		{token.VAR, "var", nil, 1},
-		{token.IDENTIFIER, "name", nil, 1},
-		{token.EQUAL, "=", nil, 1},
-		{token.NUMBER, "42", 42, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.VAR, Lexeme: "var", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "name", Line: 1},
+		{Type: token.EQUAL, Lexeme: "=", Line: 1},
+		{Type: token.NUMBER, Lexeme: "42", Literal: 42, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -44,9 +143,9 @@ func TestParser_ParseDeclaration_VarDeclaration(t *testing.T) {
 
 func TestParser_ParseStatement_ExpressionStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.NUMBER, "42", 42, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.NUMBER, Lexeme: "42", Literal: 42, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -60,26 +159,26 @@ func TestParser_ParseStatement_ExpressionStatement(t *testing.T) {
 
 func TestParser_ParseStatement_ForStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.FOR, "for", nil, 1},
-		{token.LEFT_PAREN, "(", nil, 1},
-		{token.VAR, "var", nil, 1},
-		{token.IDENTIFIER, "i", nil, 1},
-		{token.EQUAL, "=", nil, 1},
-		{token.NUMBER, "0", 0, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.IDENTIFIER, "i", nil, 1},
-		{token.LESS, "<", nil, 1},
-		{token.NUMBER, "5", 5, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.IDENTIFIER, "i", nil, 1},
-		{token.EQUAL, "=", nil, 1},
-		{token.IDENTIFIER, "i", nil, 1},
-		{token.PLUS, "+", nil, 1},
-		{token.NUMBER, "1", 1, 1},
-		{token.RIGHT_PAREN, ")", nil, 1},
-		{token.LEFT_BRACE, "{", nil, 1},
-		{token.RIGHT_BRACE, "}", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.FOR, Lexeme: "for", Line: 1},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1},
+		{Type: token.VAR, Lexeme: "var", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "i", Line: 1},
+		{Type: token.EQUAL, Lexeme: "=", Line: 1},
+		{Type: token.NUMBER, Lexeme: "0", Literal: 0, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "i", Line: 1},
+		{Type: token.LESS, Lexeme: "<", Line: 1},
+		{Type: token.NUMBER, Lexeme: "5", Literal: 5, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "i", Line: 1},
+		{Type: token.EQUAL, Lexeme: "=", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "i", Line: 1},
+		{Type: token.PLUS, Lexeme: "+", Line: 1},
+		{Type: token.NUMBER, Lexeme: "1", Literal: 1, Line: 1},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -105,18 +204,49 @@ func TestParser_ParseStatement_ForStatement(t *testing.T) {
 	}
 }
 
+func TestParser_ParseStatement_ForStatement_Span(t *testing.T) {
+	source := "for (var i = 0; i < 3; i = i + 1) print i;"
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := NewParser(tokens)
+	statement := parser.ParseStatement()
+
+	loop := statement.(ast.Block).Statements[1].(ast.While)
+	increment := loop.Increment.Span()
+	if increment.Start.Offset > increment.End.Offset {
+		t.Fatalf("increment span %v is inverted", increment)
+	}
+
+	expected := token.Span{
+		Start: token.Position{Line: 1, Column: 1, Offset: 0},
+		End:   token.Position{Line: 1, Column: len(source) + 1, Offset: len(source)},
+	}
+	if statement.Span() != expected || loop.Span() != expected {
+		t.Fatalf("expected span %v, got %v and %v", expected, statement.Span(), loop.Span())
+	}
+
+	// Joining spans out of source order still covers both.
+	joined := loop.Body.Span().Join(increment)
+	if joined.Start != increment.Start || joined.End != loop.Body.Span().End {
+		t.Fatalf("expected %v to run from the increment to the end of the body", joined)
+	}
+}
+
 func TestParser_ParseStatement_IfStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.IF, "if", nil, 1},
-		{token.LEFT_PAREN, "(", nil, 1},
-		{token.TRUE, "true", true, 1},
-		{token.RIGHT_PAREN, ")", nil, 1},
-		{token.LEFT_BRACE, "{", nil, 1},
-		{token.RIGHT_BRACE, "}", nil, 1},
-		{token.ELSE, "else", nil, 1},
-		{token.LEFT_BRACE, "{", nil, 1},
-		{token.RIGHT_BRACE, "}", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.IF, Lexeme: "if", Line: 1},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1},
+		{Type: token.TRUE, Lexeme: "true", Literal: true, Line: 1},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
+		{Type: token.ELSE, Lexeme: "else", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -145,10 +275,10 @@ func TestParser_ParseStatement_IfStatement(t *testing.T) {
 
 func TestParser_ParseStatement_PrintStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.PRINT, "print", nil, 1},
-		{token.NUMBER, "42", 42, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.PRINT, Lexeme: "print", Line: 1},
+		{Type: token.NUMBER, Lexeme: "42", Literal: 42, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -171,13 +301,13 @@ func TestParser_ParseStatement_ReturnStatement(t *testing.T) {
 
 func TestParser_ParseStatement_WhileStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.WHILE, "if", nil, 1},
-		{token.LEFT_PAREN, "(", nil, 1},
-		{token.TRUE, "true", true, 1},
-		{token.RIGHT_PAREN, ")", nil, 1},
-		{token.LEFT_BRACE, "{", nil, 1},
-		{token.RIGHT_BRACE, "}", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.WHILE, Lexeme: "if", Line: 1},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1},
+		{Type: token.TRUE, Lexeme: "true", Literal: true, Line: 1},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -201,15 +331,15 @@ func TestParser_ParseStatement_WhileStatement(t *testing.T) {
 
 func TestParser_ParseStatement_BlockStatement(t *testing.T) {
 	tokens := []token.Token{
-		{token.LEFT_BRACE, "{", nil, 1},
-		{token.PRINT, "print", nil, 1},
-		{token.NUMBER, "1", 1, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.PRINT, "print", nil, 1},
-		{token.NUMBER, "2", 2, 1},
-		{token.SEMICOLON, ";", nil, 1},
-		{token.RIGHT_BRACE, "}", nil, 1},
-		{token.EOF, "", nil, 2},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.PRINT, Lexeme: "print", Line: 1},
+		{Type: token.NUMBER, Lexeme: "1", Literal: 1, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.PRINT, Lexeme: "print", Line: 1},
+		{Type: token.NUMBER, Lexeme: "2", Literal: 2, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 2},
 	}
 
 	parser := NewParser(tokens)
@@ -234,3 +364,147 @@ func TestParser_ParseStatement_BlockStatement(t *testing.T) {
 		t.Fatal("expected a 'Print' statement")
 	}
 }
+
+func TestParser_Parse_Error(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.PRINT, Lexeme: "print", Line: 1, Column: 1},
+		{Type: token.NUMBER, Lexeme: "42", Literal: 42, Line: 1, Column: 7},
+		{Type: token.EOF, Lexeme: "", Line: 1, Column: 9},
+	}
+
+	_, err := Parse(tokens)
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatal("expected a 'ParseError'")
+	}
+
+	if parseError.Token.Type != token.EOF {
+		t.Fatal("expected the error at the end of input")
+	}
+
+	if parseError.Line != 1 || parseError.Column != 9 {
+		t.Fatal("expected the error at line 1, column 9")
+	}
+}
+
+func TestParser_Parse_Synchronize(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.PRINT, Lexeme: "print", Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.PRINT, Lexeme: "print", Line: 2},
+		{Type: token.NUMBER, Lexeme: "1", Literal: 1, Line: 2},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 2},
+		{Type: token.VAR, Lexeme: "var", Line: 3},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 3},
+		{Type: token.EOF, Lexeme: "", Line: 4},
+	}
+
+	statements, err := Parse(tokens)
+
+	list, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatal("expected a list of errors")
+	}
+
+	if len(list.Unwrap()) != 2 {
+		t.Fatal("expected two errors")
+	}
+
+	if len(statements) != 1 {
+		t.Fatal("expected one statement")
+	}
+
+	_, ok = statements[0].(ast.Print)
+	if !ok {
+		t.Fatal("expected a 'Print' statement")
+	}
+}
+
+func TestParser_Parse_BreakOutsideLoop(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.BREAK, Lexeme: "break", Line: 1, Column: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1, Column: 6},
+		{Type: token.EOF, Lexeme: "", Line: 1, Column: 7},
+	}
+
+	_, err := Parse(tokens)
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatal("expected a 'ParseError'")
+	}
+
+	if parseError.Message != "can't use 'break' outside of a loop" {
+		t.Fatalf("unexpected error message %q", parseError.Message)
+	}
+}
+
+func TestParser_Parse_ContinueInsideFunctionInsideLoop(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.WHILE, Lexeme: "while", Line: 1},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1},
+		{Type: token.TRUE, Lexeme: "true", Literal: true, Line: 1},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+		{Type: token.FUN, Lexeme: "fun", Line: 2},
+		{Type: token.IDENTIFIER, Lexeme: "f", Line: 2},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 2},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 2},
+		{Type: token.LEFT_BRACE, Lexeme: "{", Line: 2},
+		{Type: token.CONTINUE, Lexeme: "continue", Line: 2},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 2},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 2},
+		{Type: token.BREAK, Lexeme: "break", Line: 3},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 3},
+		{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 4},
+		{Type: token.EOF, Lexeme: "", Line: 5},
+	}
+
+	statements, err := Parse(tokens)
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatal("expected a 'ParseError'")
+	}
+
+	if parseError.Token.Type != token.CONTINUE {
+		t.Fatal("expected the error at 'continue'")
+	}
+
+	if len(statements) != 1 {
+		t.Fatal("expected the loop to still be parsed")
+	}
+}
+
+func TestParser_ParseExpression_Span(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.NUMBER, Lexeme: "1", Literal: 1, Line: 1, Column: 1, Offset: 0},
+		{Type: token.PLUS, Lexeme: "+", Line: 1, Column: 3, Offset: 2},
+		{Type: token.IDENTIFIER, Lexeme: "foo", Line: 1, Column: 5, Offset: 4},
+		{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1, Column: 8, Offset: 7},
+		{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1, Column: 9, Offset: 8},
+		{Type: token.EOF, Lexeme: "", Line: 1, Column: 10, Offset: 9},
+	}
+
+	parser := NewParser(tokens)
+	expression := parser.ParseExpression()
+
+	_, ok := expression.(ast.Binary)
+	if !ok {
+		t.Fatal("expected a 'Binary' expression")
+	}
+
+	expected := token.Span{
+		Start: token.Position{Line: 1, Column: 1, Offset: 0},
+		End:   token.Position{Line: 1, Column: 10, Offset: 9},
+	}
+	if expression.Span() != expected {
+		t.Fatalf("expected span %v, got %v", expected, expression.Span())
+	}
+
+	call := expression.(ast.Binary).Right.Span()
+	if call.Start.Offset != 4 || call.End.Offset != 9 {
+		t.Fatalf("expected the call to cover offsets 4-9, got %v", call)
+	}
+}
@@ -0,0 +1,329 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/token"
+)
+
+func Resolve(statements []ast.Statement) (map[ast.Expression]int, error) {
+	resolver := NewResolver()
+	resolver.ResolveStatements(statements)
+	return resolver.Locals, errors.Join(resolver.Errors...)
+}
+
+type FunctionType int
+
+const (
+	NO_FUNCTION FunctionType = iota
+	FUNCTION
+	INITIALIZER
+	METHOD
+)
+
+type ClassType int
+
+const (
+	NO_CLASS ClassType = iota
+	CLASS
+	SUBCLASS
+)
+
+type ResolveError struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("[line %d:%d] %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+func (e *ResolveError) Span() token.Span {
+	return e.Token.Span()
+}
+
+func (e *ResolveError) Reason() string {
+	return e.Message
+}
+
+type Resolver struct {
+	Scopes          []map[string]bool
+	Locals          map[ast.Expression]int
+	CurrentFunction FunctionType
+	CurrentClass    ClassType
+	Errors          []error
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{
+		Scopes:          make([]map[string]bool, 0),
+		Locals:          make(map[ast.Expression]int),
+		CurrentFunction: NO_FUNCTION,
+		CurrentClass:    NO_CLASS,
+		Errors:          make([]error, 0),
+	}
+}
+
+func (r *Resolver) ResolveStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		statement.Accept(r)
+	}
+}
+
+func (r *Resolver) BeginScope() {
+	r.Scopes = append(r.Scopes, make(map[string]bool))
+}
+
+func (r *Resolver) EndScope() {
+	r.Scopes = r.Scopes[:len(r.Scopes)-1]
+}
+
+func (r *Resolver) Declare(name token.Token) {
+	if len(r.Scopes) == 0 {
+		return
+	}
+
+	scope := r.Scopes[len(r.Scopes)-1]
+	if _, ok := scope[name.Lexeme]; ok {
+		r.Error(name, "already a variable with this name in this scope")
+	}
+
+	scope[name.Lexeme] = false
+}
+
+func (r *Resolver) Define(name token.Token) {
+	if len(r.Scopes) == 0 {
+		return
+	}
+	r.Scopes[len(r.Scopes)-1][name.Lexeme] = true
+}
+
+func (r *Resolver) ResolveLocal(expr ast.Expression, name token.Token) {
+	for i := len(r.Scopes) - 1; i >= 0; i-- {
+		if _, ok := r.Scopes[i][name.Lexeme]; ok {
+			r.Locals[expr] = len(r.Scopes) - 1 - i
+			return
+		}
+	}
+}
+
+func (r *Resolver) ResolveFunction(function *ast.Function, typ FunctionType) {
+	enclosingFunction := r.CurrentFunction
+	r.CurrentFunction = typ
+
+	r.BeginScope()
+	for _, param := range function.Params {
+		r.Declare(param)
+		r.Define(param)
+	}
+	r.ResolveStatements(function.Body)
+	r.EndScope()
+
+	r.CurrentFunction = enclosingFunction
+}
+
+func (r *Resolver) Error(name token.Token, message string) {
+	r.Errors = append(r.Errors, &ResolveError{
+		Token:   name,
+		Message: message,
+	})
+}
+
+func (r *Resolver) VisitAssignment(expr *ast.Assignment) interface{} {
+	expr.Value.Accept(r)
+	r.ResolveLocal(expr, expr.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBinary(expr *ast.Binary) interface{} {
+	expr.Left.Accept(r)
+	expr.Right.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitCall(expr *ast.Call) interface{} {
+	expr.Callee.Accept(r)
+	for _, argument := range expr.Arguments {
+		argument.Accept(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitGet(expr *ast.Get) interface{} {
+	expr.Object.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitGrouping(expr *ast.Grouping) interface{} {
+	expr.Expr.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitLiteral(expr *ast.Literal) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitLogical(expr *ast.Logical) interface{} {
+	expr.Left.Accept(r)
+	expr.Right.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitSet(expr *ast.Set) interface{} {
+	expr.Value.Accept(r)
+	expr.Object.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitSuper(expr *ast.Super) interface{} {
+	if r.CurrentClass == NO_CLASS {
+		r.Error(expr.Keyword, "can't use 'super' outside of a class")
+	} else if r.CurrentClass != SUBCLASS {
+		r.Error(expr.Keyword, "can't use 'super' in a class with no superclass")
+	}
+
+	r.ResolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitThis(expr *ast.This) interface{} {
+	if r.CurrentClass == NO_CLASS {
+		r.Error(expr.Keyword, "can't use 'this' outside of a class")
+		return nil
+	}
+
+	r.ResolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitUnary(expr *ast.Unary) interface{} {
+	expr.Operand.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitVariable(expr *ast.Variable) interface{} {
+	if len(r.Scopes) != 0 {
+		defined, ok := r.Scopes[len(r.Scopes)-1][expr.Name.Lexeme]
+		if ok && !defined {
+			r.Error(expr.Name, "can't read local variable in its own initializer")
+		}
+	}
+
+	r.ResolveLocal(expr, expr.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBlock(stmt *ast.Block) interface{} {
+	r.BeginScope()
+	r.ResolveStatements(stmt.Statements)
+	r.EndScope()
+	return nil
+}
+
+func (r *Resolver) VisitBreak(stmt *ast.Break) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitClass(stmt *ast.Class) interface{} {
+	enclosingClass := r.CurrentClass
+	r.CurrentClass = CLASS
+
+	r.Declare(stmt.Name)
+	r.Define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			r.Error(stmt.Superclass.Name, "a class can't inherit from itself")
+		}
+
+		r.CurrentClass = SUBCLASS
+		stmt.Superclass.Accept(r)
+
+		r.BeginScope()
+		r.Scopes[len(r.Scopes)-1]["super"] = true
+	}
+
+	r.BeginScope()
+	r.Scopes[len(r.Scopes)-1]["this"] = true
+
+	for index := range stmt.Methods {
+		method := &stmt.Methods[index]
+		declaration := METHOD
+		if method.Name.Lexeme == "init" {
+			declaration = INITIALIZER
+		}
+		r.ResolveFunction(method, declaration)
+	}
+
+	r.EndScope()
+
+	if stmt.Superclass != nil {
+		r.EndScope()
+	}
+
+	r.CurrentClass = enclosingClass
+	return nil
+}
+
+func (r *Resolver) VisitContinue(stmt *ast.Continue) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitExpressionStatement(stmt *ast.ExpressionStatement) interface{} {
+	stmt.Expr.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitFunction(stmt *ast.Function) interface{} {
+	r.Declare(stmt.Name)
+	r.Define(stmt.Name)
+	r.ResolveFunction(stmt, FUNCTION)
+	return nil
+}
+
+func (r *Resolver) VisitIf(stmt *ast.If) interface{} {
+	stmt.Condition.Accept(r)
+	stmt.ThenBranch.Accept(r)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitPrint(stmt *ast.Print) interface{} {
+	stmt.Expr.Accept(r)
+	return nil
+}
+
+func (r *Resolver) VisitReturn(stmt *ast.Return) interface{} {
+	if r.CurrentFunction == NO_FUNCTION {
+		r.Error(stmt.Keyword, "can't return from top-level code")
+	}
+
+	if stmt.Value != nil {
+		if r.CurrentFunction == INITIALIZER {
+			r.Error(stmt.Keyword, "can't return a value from an initializer")
+		}
+		stmt.Value.Accept(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitVar(stmt *ast.Var) interface{} {
+	r.Declare(stmt.Name)
+	if stmt.Initializer != nil {
+		stmt.Initializer.Accept(r)
+	}
+	r.Define(stmt.Name)
+	return nil
+}
+
+func (r *Resolver) VisitWhile(stmt *ast.While) interface{} {
+	stmt.Condition.Accept(r)
+	stmt.Body.Accept(r)
+	if stmt.Increment != nil {
+		stmt.Increment.Accept(r)
+	}
+	return nil
+}
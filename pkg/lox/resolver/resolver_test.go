@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"golox/pkg/lox/ast"
+	"golox/pkg/lox/parser"
+	"golox/pkg/lox/scanner"
+	"testing"
+)
+
+func parse(t *testing.T, source string) []ast.Statement {
+	tokens, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return statements
+}
+
+func TestResolver_Resolve_LocalDepth(t *testing.T) {
+	statements := parse(t, "{ var a = 1; { print a; } }")
+
+	locals, err := Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := statements[0].(ast.Block).Statements[1].(ast.Block)
+	variable := inner.Statements[0].(ast.Print).Expr
+
+	depth, ok := locals[variable]
+	if !ok {
+		t.Fatal("expected 'a' to be resolved")
+	}
+
+	if depth != 1 {
+		t.Fatalf("expected depth 1, got %d", depth)
+	}
+}
+
+func TestResolver_Resolve_Global(t *testing.T) {
+	statements := parse(t, "var a = 1; print a;")
+
+	locals, err := Resolve(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locals) != 0 {
+		t.Fatal("expected globals to be left unresolved")
+	}
+}
+
+func TestResolver_Resolve_Errors(t *testing.T) {
+	sources := []string{
+		"{ var a = a; }",
+		"{ var a = 1; var a = 2; }",
+		"return 1;",
+		"print this;",
+		"print super.method;",
+		"class A { method() { super.method(); } }",
+		"class A < A {}",
+		"class A { init() { return 1; } }",
+	}
+
+	for _, source := range sources {
+		_, err := Resolve(parse(t, source))
+		if err == nil {
+			t.Fatalf("expected an error resolving %q", source)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"fmt"
+	"golox/pkg/lox/token"
+)
+
+// ScanError is raised at text that doesn't make a token, which Token holds
+// as an ERROR token.
+type ScanError struct {
+	Token   token.Token
+	Line    int
+	Column  int
+	Message string
+}
+
+func NewScanError(t token.Token, message string) *ScanError {
+	return &ScanError{
+		Token:   t,
+		Line:    t.Line,
+		Column:  t.Column,
+		Message: message,
+	}
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("[line %d:%d] error at '%s': %s", e.Line, e.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *ScanError) Span() token.Span {
+	return e.Token.Span()
+}
+
+func (e *ScanError) Reason() string {
+	return e.Message
+}
@@ -1,37 +1,50 @@
 package scanner
 
 import (
+	"errors"
 	"golox/pkg/lox/token"
-	"log"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
-func Scan(source string) []token.Token {
+func Scan(source string) ([]token.Token, error) {
 	scanner := NewScanner(source)
-	return scanner.ScanTokens()
+	tokens := scanner.ScanTokens()
+	return tokens, errors.Join(scanner.Errors...)
 }
 
 type Scanner struct {
-	Source  string
-	Tokens  []token.Token
-	Start   int
-	Current int
-	Line    int
+	Source      string
+	Tokens      []token.Token
+	Errors      []error
+	Start       int
+	Current     int
+	Line        int
+	LineStart   int
+	StartLine   int
+	StartColumn int
 }
 
 func NewScanner(source string) *Scanner {
 	return &Scanner{
-		Source:  source,
-		Tokens:  make([]token.Token, 0),
-		Start:   0,
-		Current: 0,
-		Line:    1,
+		Source:      source,
+		Tokens:      make([]token.Token, 0),
+		Errors:      make([]error, 0),
+		Start:       0,
+		Current:     0,
+		Line:        1,
+		LineStart:   0,
+		StartLine:   1,
+		StartColumn: 1,
 	}
 }
 
 func (s *Scanner) ScanTokens() []token.Token {
 	for !s.IsAtEnd() {
 		s.Start = s.Current
+		s.StartLine = s.Line
+		s.StartColumn = s.Current - s.LineStart + 1
 		s.ScanToken()
 	}
 
@@ -40,6 +53,8 @@ func (s *Scanner) ScanTokens() []token.Token {
 		Lexeme:  "",
 		Literal: nil,
 		Line:    s.Line,
+		Column:  s.Current - s.LineStart + 1,
+		Offset:  s.Current,
 	}
 
 	s.Tokens = append(s.Tokens, eof)
@@ -74,6 +89,8 @@ func (s *Scanner) ScanToken() {
 		s.AddToken(token.SEMICOLON)
 	case '*':
 		s.AddToken(token.STAR)
+	case '%':
+		s.AddToken(token.PERCENT)
 	case '!':
 		if s.Match('=') {
 			s.AddToken(token.BANG_EQUAL)
@@ -110,7 +127,7 @@ func (s *Scanner) ScanToken() {
 	case '\r':
 	case '\t':
 	case '\n':
-		s.Line++
+		s.NewLine()
 	case '"':
 		s.ScanString()
 	default:
@@ -119,7 +136,7 @@ func (s *Scanner) ScanToken() {
 		} else if IsAlpha(c) {
 			s.ScanIdentifier()
 		} else {
-			log.Fatalln("unexpected character")
+			s.Error("unexpected character")
 		}
 	}
 }
@@ -136,7 +153,34 @@ func (s *Scanner) AddToken(tokenType token.TokenType) {
 
 func (s *Scanner) AddTokenWithValue(tokenType token.TokenType, literal interface{}) {
 	text := s.Source[s.Start:s.Current]
-	s.Tokens = append(s.Tokens, token.Token{tokenType, text, literal, s.Line})
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:    tokenType,
+		Lexeme:  text,
+		Literal: literal,
+		Line:    s.StartLine,
+		Column:  s.StartColumn,
+		Offset:  s.Start,
+	})
+}
+
+func (s *Scanner) NewLine() {
+	s.Line++
+	s.LineStart = s.Current
+}
+
+func (s *Scanner) Error(message string) {
+	lexeme := s.Source[s.Start:s.Current]
+	if end := strings.IndexByte(lexeme, '\n'); end >= 0 {
+		lexeme = lexeme[:end]
+	}
+
+	s.Errors = append(s.Errors, NewScanError(token.Token{
+		Type:   token.ERROR,
+		Lexeme: lexeme,
+		Line:   s.StartLine,
+		Column: s.StartColumn,
+		Offset: s.Start,
+	}, message))
 }
 
 func (s *Scanner) Match(expected byte) bool {
@@ -162,14 +206,15 @@ func (s *Scanner) Peek() byte {
 
 func (s *Scanner) ScanString() {
 	for s.Peek() != '"' && !s.IsAtEnd() {
-		if s.Peek() == '\n' {
-			s.Line++
-		}
 		s.Advance()
+		if s.Previous() == '\n' {
+			s.NewLine()
+		}
 	}
 
 	if s.IsAtEnd() {
-		log.Fatalln("unterminated string")
+		s.Error("unterminated string")
+		return
 	}
 
 	s.Advance()
@@ -179,23 +224,60 @@ func (s *Scanner) ScanString() {
 }
 
 func (s *Scanner) ScanNumber() {
-	for IsDigit(s.Peek()) {
+	base := 10
+	isDigit := IsDigit
+	if s.Previous() == '0' && (s.Peek() == 'x' || s.Peek() == 'X') && IsHexDigit(s.PeekNext()) {
+		base = 16
+		isDigit = IsHexDigit
+		s.Advance()
+	}
+
+	for isDigit(s.Peek()) || s.Peek() == '_' {
 		s.Advance()
 	}
 
-	if s.Peek() == '.' && IsDigit(s.PeekNext()) {
+	isFloat := false
+	if base == 10 && s.Peek() == '.' && IsDigit(s.PeekNext()) {
+		isFloat = true
 		s.Advance()
-		for IsDigit(s.Peek()) {
+		for IsDigit(s.Peek()) || s.Peek() == '_' {
 			s.Advance()
 		}
 	}
 
-	value, err := strconv.ParseFloat(s.Source[s.Start:s.Current], 32)
-	if err != nil {
-		log.Fatalln(err)
+	text := s.Source[s.Start:s.Current]
+	if strings.HasSuffix(text, "_") || strings.Contains(text, "__") || strings.Contains(text, "_.") || strings.Contains(text, "._") {
+		s.Error("invalid number literal")
+		return
 	}
 
-	s.AddTokenWithValue(token.NUMBER, float32(value))
+	digits := strings.ReplaceAll(text, "_", "")
+	if base == 16 {
+		digits = digits[2:]
+	}
+
+	if isFloat {
+		value, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			s.Error("invalid number literal")
+			return
+		}
+		s.AddTokenWithValue(token.NUMBER, value)
+		return
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err == nil {
+		s.AddTokenWithValue(token.NUMBER, value)
+		return
+	}
+
+	integer, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		s.Error("invalid number literal")
+		return
+	}
+	s.AddTokenWithValue(token.NUMBER, integer)
 }
 
 func (s *Scanner) ScanIdentifier() {
@@ -211,6 +293,10 @@ func (s *Scanner) ScanIdentifier() {
 	s.AddToken(tokenType)
 }
 
+func (s *Scanner) Previous() byte {
+	return s.Source[s.Current-1]
+}
+
 func (s *Scanner) PeekNext() byte {
 	if s.Current+1 >= len(s.Source) {
 		return '\000'
@@ -222,6 +308,12 @@ func IsDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
+func IsHexDigit(c byte) bool {
+	return IsDigit(c) ||
+		(c >= 'a' && c <= 'f') ||
+		(c >= 'A' && c <= 'F')
+}
+
 func IsAlpha(c byte) bool {
 	return (c >= 'a' && c <= 'z') ||
 		(c >= 'A' && c <= 'Z') ||
@@ -0,0 +1,87 @@
+package scanner
+
+import (
+	"golox/pkg/lox/token"
+	"math/big"
+	"testing"
+)
+
+func TestScanner_ScanTokens_Positions(t *testing.T) {
+	tokens, err := Scan("var a =\n  \"x\ny\";")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []token.Span{
+		{Start: token.Position{Line: 1, Column: 1, Offset: 0}, End: token.Position{Line: 1, Column: 4, Offset: 3}},
+		{Start: token.Position{Line: 1, Column: 5, Offset: 4}, End: token.Position{Line: 1, Column: 6, Offset: 5}},
+		{Start: token.Position{Line: 1, Column: 7, Offset: 6}, End: token.Position{Line: 1, Column: 8, Offset: 7}},
+		{Start: token.Position{Line: 2, Column: 3, Offset: 10}, End: token.Position{Line: 3, Column: 3, Offset: 15}},
+		{Start: token.Position{Line: 3, Column: 3, Offset: 15}, End: token.Position{Line: 3, Column: 4, Offset: 16}},
+		{Start: token.Position{Line: 3, Column: 4, Offset: 16}, End: token.Position{Line: 3, Column: 4, Offset: 16}},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+
+	for i, span := range expected {
+		if tokens[i].Span() != span {
+			t.Fatalf("expected token %d to span %v, got %v", i, span, tokens[i].Span())
+		}
+	}
+}
+
+func TestScanner_ScanTokens_Error(t *testing.T) {
+	_, err := Scan("var a = 1;\n  @")
+
+	scanError, ok := err.(interface{ Unwrap() []error }).Unwrap()[0].(*ScanError)
+	if !ok {
+		t.Fatal("expected a 'ScanError'")
+	}
+
+	if scanError.Line != 2 || scanError.Column != 3 {
+		t.Fatalf("expected the error at 2:3, got %d:%d", scanError.Line, scanError.Column)
+	}
+
+	if scanError.Token.Type != token.ERROR || scanError.Token.Lexeme != "@" || scanError.Token.Offset != 13 {
+		t.Fatalf("expected the error to carry the offending text, got %+v", scanError.Token)
+	}
+}
+
+func TestScanner_ScanNumber_Literals(t *testing.T) {
+	literals := map[string]interface{}{
+		"42":        int64(42),
+		"0x1F":      int64(31),
+		"1_000_000": int64(1000000),
+		"2.5":       2.5,
+		"1_0.2_5":   10.25,
+	}
+
+	for source, expected := range literals {
+		tokens, err := Scan(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tokens[0].Literal != expected {
+			t.Fatalf("expected %q to scan as %v, got %v", source, expected, tokens[0].Literal)
+		}
+	}
+
+	tokens, err := Scan("99999999999999999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens[0].Literal.(*big.Int).String() != "99999999999999999999" {
+		t.Fatal("expected an arbitrary-precision literal")
+	}
+
+	for _, source := range []string{"1_", "1__0", "1_.5"} {
+		_, err := Scan(source)
+		if err == nil {
+			t.Fatalf("expected an error scanning %q", source)
+		}
+	}
+}
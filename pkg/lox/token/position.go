@@ -0,0 +1,58 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a location in source text. Line and Column are 1-based, Column
+// and Offset count bytes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func (p Position) Advance(text string) Position {
+	last := strings.LastIndexByte(text, '\n')
+	if last < 0 {
+		return Position{
+			Line:   p.Line,
+			Column: p.Column + len(text),
+			Offset: p.Offset + len(text),
+		}
+	}
+
+	return Position{
+		Line:   p.Line + strings.Count(text, "\n"),
+		Column: len(text) - last,
+		Offset: p.Offset + len(text),
+	}
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the half-open range of source text [Start, End).
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Join covers both spans and everything between them, whichever comes first
+// in the source.
+func (s Span) Join(other Span) Span {
+	joined := Span{Start: s.Start, End: other.End}
+	if other.Start.Offset < joined.Start.Offset {
+		joined.Start = other.Start
+	}
+	if s.End.Offset > joined.End.Offset {
+		joined.End = s.End
+	}
+	return joined
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%s", s.Start, s.End)
+}
@@ -16,6 +16,7 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
 	BANG
 	BANG_EQUAL
 	EQUAL
@@ -28,7 +29,9 @@ const (
 	STRING
 	NUMBER
 	AND
+	BREAK
 	CLASS
+	CONTINUE
 	ELSE
 	FALSE
 	FUN
@@ -44,6 +47,9 @@ const (
 	VAR
 	WHILE
 	EOF
+
+	// ERROR is never scanned. It marks the text a ScanError is about.
+	ERROR
 )
 
 type Token struct {
@@ -51,25 +57,48 @@ type Token struct {
 	Lexeme  string
 	Literal interface{}
 	Line    int
+	Column  int
+	Offset  int
+}
+
+func (t Token) Start() Position {
+	return Position{
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+	}
+}
+
+func (t Token) End() Position {
+	return t.Start().Advance(t.Lexeme)
+}
+
+func (t Token) Span() Span {
+	return Span{
+		Start: t.Start(),
+		End:   t.End(),
+	}
 }
 
 var Keywords = map[string]TokenType{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":      AND,
+	"break":    BREAK,
+	"class":    CLASS,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"true":     TRUE,
+	"var":      VAR,
+	"while":    WHILE,
 }
 
 var enumNames = map[TokenType]string{
@@ -84,6 +113,7 @@ var enumNames = map[TokenType]string{
 	SEMICOLON:     "SEMICOLON",
 	SLASH:         "SLASH",
 	STAR:          "STAR",
+	PERCENT:       "PERCENT",
 	BANG:          "BANG",
 	BANG_EQUAL:    "BANG_EQUAL",
 	EQUAL:         "EQUAL",
@@ -96,7 +126,9 @@ var enumNames = map[TokenType]string{
 	STRING:        "STRING",
 	NUMBER:        "NUMBER",
 	AND:           "AND",
+	BREAK:         "BREAK",
 	CLASS:         "CLASS",
+	CONTINUE:      "CONTINUE",
 	ELSE:          "ELSE",
 	FALSE:         "FALSE",
 	FUN:           "FUN",
@@ -112,6 +144,7 @@ var enumNames = map[TokenType]string{
 	VAR:           "VAR",
 	WHILE:         "WHILE",
 	EOF:           "EOF",
+	ERROR:         "ERROR",
 }
 
 func (e TokenType) String() string {
@@ -0,0 +1,48 @@
+package vm
+
+import "golox/pkg/lox/compiler"
+
+type Closure struct {
+	Function *compiler.Function
+	Upvalues []*Upvalue
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// Upvalue is a variable captured by a closure. While open it refers to a slot
+// on the VM stack; once that slot is popped the value moves into Closed.
+type Upvalue struct {
+	Location int
+	Closed   interface{}
+	IsClosed bool
+	Next     *Upvalue
+}
+
+type Class struct {
+	Name    string
+	Methods map[string]*Closure
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+type Instance struct {
+	Class  *Class
+	Fields map[string]interface{}
+}
+
+func (o *Instance) String() string {
+	return o.Class.Name + " instance"
+}
+
+type BoundMethod struct {
+	Receiver interface{}
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
@@ -0,0 +1,492 @@
+package vm
+
+import (
+	"fmt"
+	"golox/pkg/lox/compiler"
+	"golox/pkg/lox/interpreter"
+	"golox/pkg/lox/token"
+	"io"
+	"os"
+)
+
+// FRAMES_MAX includes the script's own frame, so as many calls can be in
+// progress as on the tree-walk interpreter.
+const FRAMES_MAX = interpreter.MAX_DEPTH + 1
+
+type CallFrame struct {
+	Closure *Closure
+	IP      int
+	Slots   int
+}
+
+type VM struct {
+	Frames       []CallFrame
+	Stack        []interface{}
+	Globals      map[string]interface{}
+	OpenUpvalues *Upvalue
+	Writer       io.Writer
+}
+
+func NewVM() *VM {
+	vm := &VM{
+		Frames:  make([]CallFrame, 0, 64),
+		Stack:   make([]interface{}, 0, 256),
+		Globals: make(map[string]interface{}),
+		Writer:  os.Stdout,
+	}
+	for _, native := range interpreter.Builtins {
+		vm.Globals[native.Name] = native
+	}
+	return vm
+}
+
+// DefineNative exposes a Go function to scripts as a global, as
+// Interpreter.DefineNative does for the tree-walk interpreter.
+func (vm *VM) DefineNative(name string, arity int, function func(arguments []interface{}) (interface{}, error)) {
+	vm.Globals[name] = &interpreter.NativeFunction{
+		Name:     name,
+		ArgCount: arity,
+		Function: function,
+	}
+}
+
+// Interpret runs a compiled script. Runtime errors are returned as
+// *interpreter.RuntimeError so both backends report them the same way.
+func (vm *VM) Interpret(function *compiler.Function) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		runtimeError, ok := r.(*interpreter.RuntimeError)
+		if !ok {
+			panic(r)
+		}
+
+		vm.Reset()
+		err = runtimeError
+	}()
+
+	closure := &Closure{
+		Function: function,
+	}
+	vm.Push(closure)
+	vm.Call(closure, 0)
+	vm.Run()
+
+	return nil
+}
+
+func (vm *VM) Reset() {
+	vm.Frames = vm.Frames[:0]
+	vm.Stack = vm.Stack[:0]
+	vm.OpenUpvalues = nil
+}
+
+func (vm *VM) Push(value interface{}) {
+	vm.Stack = append(vm.Stack, value)
+}
+
+func (vm *VM) Pop() interface{} {
+	value := vm.Stack[len(vm.Stack)-1]
+	vm.Stack = vm.Stack[:len(vm.Stack)-1]
+	return value
+}
+
+func (vm *VM) Peek(distance int) interface{} {
+	return vm.Stack[len(vm.Stack)-1-distance]
+}
+
+// Token returns the source token of the instruction currently executing.
+func (vm *VM) Token() token.Token {
+	frame := &vm.Frames[len(vm.Frames)-1]
+	return frame.Closure.Function.Chunk.TokenAt(frame.IP - 1)
+}
+
+func (vm *VM) Error(format string, args ...interface{}) {
+	panic(interpreter.NewRuntimeError(vm.Token(), fmt.Sprintf(format, args...)))
+}
+
+func (vm *VM) Call(closure *Closure, argCount int) {
+	if argCount != closure.Function.Arity {
+		vm.Error("expected %d arguments but got %d", closure.Function.Arity, argCount)
+	}
+
+	if len(vm.Frames) == FRAMES_MAX {
+		vm.Error("stack overflow")
+	}
+
+	vm.Frames = append(vm.Frames, CallFrame{
+		Closure: closure,
+		IP:      0,
+		Slots:   len(vm.Stack) - argCount - 1,
+	})
+}
+
+func (vm *VM) CallValue(callee interface{}, argCount int) {
+	switch callee.(type) {
+	case *Closure:
+		vm.Call(callee.(*Closure), argCount)
+	case *interpreter.NativeFunction:
+		native := callee.(*interpreter.NativeFunction)
+		if argCount != native.ArgCount {
+			vm.Error("expected %d arguments but got %d", native.ArgCount, argCount)
+		}
+		arguments := make([]interface{}, argCount)
+		copy(arguments, vm.Stack[len(vm.Stack)-argCount:])
+		result, err := native.Function(arguments)
+		if err != nil {
+			vm.Error("%s", err)
+		}
+		vm.Stack = vm.Stack[:len(vm.Stack)-argCount-1]
+		vm.Push(result)
+	case *BoundMethod:
+		bound := callee.(*BoundMethod)
+		vm.Stack[len(vm.Stack)-argCount-1] = bound.Receiver
+		vm.Call(bound.Method, argCount)
+	case *Class:
+		class := callee.(*Class)
+		vm.Stack[len(vm.Stack)-argCount-1] = &Instance{
+			Class:  class,
+			Fields: make(map[string]interface{}),
+		}
+		if initializer, ok := class.Methods["init"]; ok {
+			vm.Call(initializer, argCount)
+		} else if argCount != 0 {
+			vm.Error("expected 0 arguments but got %d", argCount)
+		}
+	default:
+		vm.Error("can only call functions and classes")
+	}
+}
+
+// TailCall makes the call a return statement returns. A function or method
+// runs in place of the frame making the call, so tail recursion runs in a
+// constant number of frames as it does on the tree-walk interpreter. Anything
+// else is called as usual, and the OP_RETURN after the call returns its
+// result.
+func (vm *VM) TailCall(callee interface{}, argCount int) {
+	var closure *Closure
+	switch callee.(type) {
+	case *Closure:
+		closure = callee.(*Closure)
+	case *BoundMethod:
+		bound := callee.(*BoundMethod)
+		vm.Stack[len(vm.Stack)-argCount-1] = bound.Receiver
+		closure = bound.Method
+	default:
+		vm.CallValue(callee, argCount)
+		return
+	}
+
+	if argCount != closure.Function.Arity {
+		vm.Error("expected %d arguments but got %d", closure.Function.Arity, argCount)
+	}
+
+	frame := &vm.Frames[len(vm.Frames)-1]
+	vm.CloseUpvalues(frame.Slots)
+	count := copy(vm.Stack[frame.Slots:], vm.Stack[len(vm.Stack)-argCount-1:])
+	vm.Stack = vm.Stack[:frame.Slots+count]
+	frame.Closure = closure
+	frame.IP = 0
+}
+
+func (vm *VM) BindMethod(class *Class, name string) {
+	method, ok := class.Methods[name]
+	if !ok {
+		vm.Error("undefined property '%s'", name)
+	}
+
+	bound := &BoundMethod{
+		Receiver: vm.Peek(0),
+		Method:   method,
+	}
+	vm.Pop()
+	vm.Push(bound)
+}
+
+func (vm *VM) CaptureUpvalue(location int) *Upvalue {
+	var previous *Upvalue
+	upvalue := vm.OpenUpvalues
+	for upvalue != nil && upvalue.Location > location {
+		previous = upvalue
+		upvalue = upvalue.Next
+	}
+
+	if upvalue != nil && upvalue.Location == location {
+		return upvalue
+	}
+
+	created := &Upvalue{
+		Location: location,
+		Next:     upvalue,
+	}
+
+	if previous == nil {
+		vm.OpenUpvalues = created
+	} else {
+		previous.Next = created
+	}
+
+	return created
+}
+
+func (vm *VM) CloseUpvalues(last int) {
+	for vm.OpenUpvalues != nil && vm.OpenUpvalues.Location >= last {
+		upvalue := vm.OpenUpvalues
+		upvalue.Closed = vm.Stack[upvalue.Location]
+		upvalue.IsClosed = true
+		vm.OpenUpvalues = upvalue.Next
+	}
+}
+
+func (vm *VM) GetUpvalue(upvalue *Upvalue) interface{} {
+	if upvalue.IsClosed {
+		return upvalue.Closed
+	}
+	return vm.Stack[upvalue.Location]
+}
+
+func (vm *VM) SetUpvalue(upvalue *Upvalue, value interface{}) {
+	if upvalue.IsClosed {
+		upvalue.Closed = value
+	} else {
+		vm.Stack[upvalue.Location] = value
+	}
+}
+
+func (vm *VM) CheckNumberOperands() {
+	if !interpreter.IsNumber(vm.Peek(0)) || !interpreter.IsNumber(vm.Peek(1)) {
+		vm.Error("operands must be numbers")
+	}
+}
+
+func (vm *VM) Run() {
+	frame := &vm.Frames[len(vm.Frames)-1]
+	code := frame.Closure.Function.Chunk.Code
+	constants := frame.Closure.Function.Chunk.Constants
+
+	readByte := func() byte {
+		b := code[frame.IP]
+		frame.IP++
+		return b
+	}
+
+	readShort := func() int {
+		frame.IP += 2
+		return int(code[frame.IP-2])<<8 | int(code[frame.IP-1])
+	}
+
+	readString := func() string {
+		return constants[readShort()].(string)
+	}
+
+	reload := func() {
+		frame = &vm.Frames[len(vm.Frames)-1]
+		code = frame.Closure.Function.Chunk.Code
+		constants = frame.Closure.Function.Chunk.Constants
+	}
+
+	for {
+		switch compiler.OpCode(readByte()) {
+		case compiler.OP_CONSTANT:
+			vm.Push(constants[readShort()])
+		case compiler.OP_NIL:
+			vm.Push(nil)
+		case compiler.OP_TRUE:
+			vm.Push(true)
+		case compiler.OP_FALSE:
+			vm.Push(false)
+		case compiler.OP_POP:
+			vm.Pop()
+		case compiler.OP_GET_LOCAL:
+			vm.Push(vm.Stack[frame.Slots+int(readByte())])
+		case compiler.OP_SET_LOCAL:
+			vm.Stack[frame.Slots+int(readByte())] = vm.Peek(0)
+		case compiler.OP_GET_GLOBAL:
+			name := readString()
+			value, ok := vm.Globals[name]
+			if !ok {
+				vm.Error("undefined variable '%s'", name)
+			}
+			vm.Push(value)
+		case compiler.OP_DEFINE_GLOBAL:
+			vm.Globals[readString()] = vm.Pop()
+		case compiler.OP_SET_GLOBAL:
+			name := readString()
+			if _, ok := vm.Globals[name]; !ok {
+				vm.Error("undefined variable '%s'", name)
+			}
+			vm.Globals[name] = vm.Peek(0)
+		case compiler.OP_GET_UPVALUE:
+			vm.Push(vm.GetUpvalue(frame.Closure.Upvalues[readByte()]))
+		case compiler.OP_SET_UPVALUE:
+			vm.SetUpvalue(frame.Closure.Upvalues[readByte()], vm.Peek(0))
+		case compiler.OP_GET_PROPERTY:
+			name := readString()
+			instance, ok := vm.Peek(0).(*Instance)
+			if !ok {
+				vm.Error("only instances have properties")
+			}
+			if value, ok := instance.Fields[name]; ok {
+				vm.Pop()
+				vm.Push(value)
+				break
+			}
+			vm.BindMethod(instance.Class, name)
+		case compiler.OP_SET_PROPERTY:
+			name := readString()
+			instance, ok := vm.Peek(1).(*Instance)
+			if !ok {
+				vm.Error("only instances have fields")
+			}
+			instance.Fields[name] = vm.Peek(0)
+			value := vm.Pop()
+			vm.Pop()
+			vm.Push(value)
+		case compiler.OP_GET_SUPER:
+			name := readString()
+			superclass := vm.Pop().(*Class)
+			vm.BindMethod(superclass, name)
+		case compiler.OP_EQUAL:
+			b := vm.Pop()
+			a := vm.Pop()
+			vm.Push(interpreter.IsEqual(a, b))
+		case compiler.OP_NOT_EQUAL:
+			b := vm.Pop()
+			a := vm.Pop()
+			vm.Push(!interpreter.IsEqual(a, b))
+		case compiler.OP_GREATER, compiler.OP_GREATER_EQUAL, compiler.OP_LESS, compiler.OP_LESS_EQUAL:
+			op := compiler.OpCode(code[frame.IP-1])
+			vm.CheckNumberOperands()
+			b := vm.Pop()
+			a := vm.Pop()
+			result, ok := interpreter.Compare(a, b)
+			switch op {
+			case compiler.OP_GREATER:
+				vm.Push(ok && result > 0)
+			case compiler.OP_GREATER_EQUAL:
+				vm.Push(ok && result >= 0)
+			case compiler.OP_LESS:
+				vm.Push(ok && result < 0)
+			case compiler.OP_LESS_EQUAL:
+				vm.Push(ok && result <= 0)
+			}
+		case compiler.OP_ADD:
+			a, aok := vm.Peek(1).(string)
+			b, bok := vm.Peek(0).(string)
+			if aok && bok {
+				vm.Pop()
+				vm.Pop()
+				vm.Push(a + b)
+				break
+			}
+			if !interpreter.IsNumber(vm.Peek(0)) || !interpreter.IsNumber(vm.Peek(1)) {
+				vm.Error("operands must be two numbers or two strings")
+			}
+			y := vm.Pop()
+			x := vm.Pop()
+			vm.Push(interpreter.Add(x, y))
+		case compiler.OP_SUBTRACT:
+			vm.CheckNumberOperands()
+			y := vm.Pop()
+			x := vm.Pop()
+			vm.Push(interpreter.Subtract(x, y))
+		case compiler.OP_MULTIPLY:
+			vm.CheckNumberOperands()
+			y := vm.Pop()
+			x := vm.Pop()
+			vm.Push(interpreter.Multiply(x, y))
+		case compiler.OP_DIVIDE:
+			vm.CheckNumberOperands()
+			y := vm.Pop()
+			x := vm.Pop()
+			vm.Push(interpreter.Divide(vm.Token(), x, y))
+		case compiler.OP_MODULO:
+			vm.CheckNumberOperands()
+			y := vm.Pop()
+			x := vm.Pop()
+			vm.Push(interpreter.Modulo(vm.Token(), x, y))
+		case compiler.OP_NOT:
+			vm.Push(!interpreter.IsTruthy(vm.Pop()))
+		case compiler.OP_NEGATE:
+			if !interpreter.IsNumber(vm.Peek(0)) {
+				vm.Error("operand must be a number")
+			}
+			vm.Push(interpreter.Negate(vm.Pop()))
+		case compiler.OP_PRINT:
+			fmt.Fprintln(vm.Writer, interpreter.Stringify(vm.Pop()))
+		case compiler.OP_JUMP:
+			offset := readShort()
+			frame.IP += offset
+		case compiler.OP_JUMP_IF_FALSE:
+			offset := readShort()
+			if !interpreter.IsTruthy(vm.Peek(0)) {
+				frame.IP += offset
+			}
+		case compiler.OP_LOOP:
+			offset := readShort()
+			frame.IP -= offset
+		case compiler.OP_CALL:
+			argCount := int(readByte())
+			vm.CallValue(vm.Peek(argCount), argCount)
+			reload()
+		case compiler.OP_TAIL_CALL:
+			argCount := int(readByte())
+			vm.TailCall(vm.Peek(argCount), argCount)
+			reload()
+		case compiler.OP_CLOSURE:
+			function := constants[readShort()].(*compiler.Function)
+			closure := &Closure{
+				Function: function,
+				Upvalues: make([]*Upvalue, function.UpvalueCount),
+			}
+			for i := range closure.Upvalues {
+				isLocal := readByte()
+				index := int(readByte())
+				if isLocal == 1 {
+					closure.Upvalues[i] = vm.CaptureUpvalue(frame.Slots + index)
+				} else {
+					closure.Upvalues[i] = frame.Closure.Upvalues[index]
+				}
+			}
+			vm.Push(closure)
+		case compiler.OP_CLOSE_UPVALUE:
+			vm.CloseUpvalues(len(vm.Stack) - 1)
+			vm.Pop()
+		case compiler.OP_RETURN:
+			result := vm.Pop()
+			vm.CloseUpvalues(frame.Slots)
+			slots := frame.Slots
+			vm.Frames = vm.Frames[:len(vm.Frames)-1]
+			vm.Stack = vm.Stack[:slots]
+			if len(vm.Frames) == 0 {
+				return
+			}
+			vm.Push(result)
+			reload()
+		case compiler.OP_CLASS:
+			vm.Push(&Class{
+				Name:    readString(),
+				Methods: make(map[string]*Closure),
+			})
+		case compiler.OP_INHERIT:
+			superclass, ok := vm.Peek(1).(*Class)
+			if !ok {
+				vm.Error("superclass must be a class")
+			}
+			subclass := vm.Peek(0).(*Class)
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+			vm.Pop()
+		case compiler.OP_METHOD:
+			name := readString()
+			method := vm.Peek(0).(*Closure)
+			class := vm.Peek(1).(*Class)
+			class.Methods[name] = method
+			vm.Pop()
+		}
+	}
+}